@@ -61,6 +61,8 @@ type Cache interface {
 	Expire(key string, time int64) (bool, error)
 	// delete cached value by key.
 	Delete(key string) error
+	// rename key, replace newKey atomically if it exists
+	Rename(oldKey, newKey string) error
 	// increase cached int value by key, as a counter.
 	Incr(key string) (int, error)
 	// increase cached int value by key, as a counter.
@@ -141,6 +143,10 @@ type Cache interface {
 	LRem(key string, count int, value string) error
 	// range list
 	Lrange(key string, start, stop int) ([]string, error)
+	// enable expired keyevent notifications, false when the server refuses it
+	EnableExpiredEvents() (bool, error)
+	// subscribe expired keyevent, blocks until stop is closed or the connection fails
+	SubscribeExpired(stop <-chan struct{}, handler func(key string)) error
 }
 
 // Instance is a function create a new Cache Instance
@@ -200,6 +200,12 @@ func (rc *Cache) Delete(key string) error {
 	return err
 }
 
+// Rename rename key in redis, newKey is replaced atomically if it exists.
+func (rc *Cache) Rename(oldKey, newKey string) error {
+	_, err := rc.do("RENAME", oldKey, rc.associate(newKey))
+	return err
+}
+
 // IsExist check cache's existence in redis.
 func (rc *Cache) IsExist(key string) bool {
 	v, err := redis.Bool(rc.do("EXISTS", key))
@@ -498,6 +504,69 @@ func (rc *Cache) Lrange(key string, start, stop int) ([]string, error) {
 	return redis.Strings(c.Do("LRANGE", key, start, stop))
 }
 
+// EnableExpiredEvents make sure notify-keyspace-events contains the expired keyevent flags.
+// return false when the server refuses CONFIG (e.g. managed redis), caller should poll instead.
+func (rc *Cache) EnableExpiredEvents() (bool, error) {
+	c := rc.p.Get()
+	defer c.Close()
+	reply, err := redis.Strings(c.Do("CONFIG", "GET", "notify-keyspace-events"))
+	if err != nil {
+		return false, err
+	}
+	flags := ""
+	if len(reply) == 2 {
+		flags = reply[1]
+	}
+	if strings.Contains(flags, "E") && (strings.Contains(flags, "x") || strings.Contains(flags, "A")) {
+		return true, nil
+	}
+	if !strings.Contains(flags, "E") {
+		flags += "E"
+	}
+	if !strings.Contains(flags, "x") && !strings.Contains(flags, "A") {
+		flags += "x"
+	}
+	if _, err = c.Do("CONFIG", "SET", "notify-keyspace-events", flags); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// SubscribeExpired subscribe expired keyevent of current db,
+// handler receives the key without the collection prefix, keys of other collections are ignored.
+func (rc *Cache) SubscribeExpired(stop <-chan struct{}, handler func(key string)) error {
+	psc := redis.PubSubConn{Conn: rc.p.Get()}
+	defer psc.Close()
+	channel := fmt.Sprintf("__keyevent@%d__:expired", rc.dbNum)
+	if err := psc.Subscribe(channel); err != nil {
+		return err
+	}
+
+	prefix := rc.key + ":"
+	done := make(chan error, 1)
+	go func() {
+		for {
+			switch v := psc.Receive().(type) {
+			case redis.Message:
+				if key := string(v.Data); strings.HasPrefix(key, prefix) {
+					handler(strings.TrimPrefix(key, prefix))
+				}
+			case error:
+				done <- v
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-stop:
+		psc.Unsubscribe()
+		return nil
+	case err := <-done:
+		return err
+	}
+}
+
 // connect to redis.
 func (rc *Cache) connectInit() {
 	dialFunc := func() (c redis.Conn, err error) {
@@ -0,0 +1,9 @@
+package conf
+
+import "github.com/astaxie/beego"
+
+// SecretKey jwt签名密钥
+var SecretKey = beego.AppConfig.String("jwt::secret_key")
+
+// XsrfKey xsrf token签名密钥, 与jwt密钥分开配置
+var XsrfKey = beego.AppConfig.String("xsrf::key")
@@ -19,8 +19,13 @@ const LogOperateTypeNotify = 4
 const LogOperateTypeCancel = 5
 const LogOperateTypeEdit = 6
 const LogOperateTypeConfirm = 7
+const LogOperateTypeComplete = 8
+const LogOperateTypeNoShow = 9
+const LogOperateTypeDeposit = 10
 
 const (
 	DayLayout      = "02/01/2006"
+	BizDateLayout  = "2006-01-02" //营业日, 与<input type="date">一致
+	ClockLayout    = "15:04"
 	DateTimeLayout = "02/01/2006 15:04:05"
 )
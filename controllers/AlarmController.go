@@ -0,0 +1,15 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+)
+
+type AlarmController struct {
+	BaseController
+}
+
+// Poll 前台定时拉取待响铃的提醒
+func (c *AlarmController) Poll() {
+	c.jsonResult(enums.JRCodeSucc, "", models.PopAlarms(c.curMerchantId()))
+}
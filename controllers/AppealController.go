@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"encoding/json"
+)
+
+// AppealController 订台归属申诉
+type AppealController struct {
+	BaseController
+}
+
+func (c *AppealController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+type appealParams struct {
+	Id       int    `json:"id"`
+	SiteName string `json:"site_name"`
+	Date     string `json:"date"`
+	Evidence string `json:"evidence"`
+	Approve  bool   `json:"approve"`
+	Remark   string `json:"remark"`
+}
+
+func (c *AppealController) parseParams() *appealParams {
+	var params appealParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	return &params
+}
+
+// List 申诉列表, 经理看全部, 其他员工只看自己的
+func (c *AppealController) List() {
+	status, _ := c.GetInt("status", 0)
+	staffId := c.curStaff.Id
+	if c.curStaff.IsManager() {
+		staffId = 0
+	}
+	c.jsonResult(enums.JRCodeSucc, "", models.AppealList(c.curMerchantId(), staffId, status))
+}
+
+// Create 对订台的预定人发起申诉
+func (c *AppealController) Create() {
+	params := c.parseParams()
+	date := c.bizDate(models.MerchantSettingOne(c.curMerchantId()), params.Date)
+	m, err := models.AppealCreate(c.curStaff, date, params.SiteName, params.Evidence)
+	if err != nil {
+		models.AddBarLog(c.curStaff, conf.LogOperateTypeAppeal, "申诉 "+date+" "+params.SiteName, err)
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeAppeal, models.AppealLogRemark(m, "发起"), nil)
+	c.jsonResult(enums.JRCodeSucc, "申诉已提交", m)
+}
+
+// Review 经理审核申诉, 通过后订台归申诉人
+func (c *AppealController) Review() {
+	c.checkManager()
+	params := c.parseParams()
+	m, err := models.AppealReview(c.curStaff, params.Id, params.Approve, params.Remark)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeAppeal, models.AppealLogRemark(m, "审核"), nil)
+	c.jsonResult(enums.JRCodeSucc, m.StatusName(), m)
+}
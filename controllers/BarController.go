@@ -0,0 +1,394 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type BarController struct {
+	BaseController
+}
+
+// 拆分逗号分隔的台号
+func splitSites(str string) []string {
+	var sites []string
+	for _, v := range strings.Split(str, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			sites = append(sites, v)
+		}
+	}
+	return sites
+}
+
+// Index 订台平面图
+func (c *BarController) Index() {
+	layout, _ := c.GetInt("type", 0)
+	if layout < 0 || layout >= len(models.LayoutImages) {
+		layout = 0
+	}
+	merchantId := c.curMerchantId()
+	setting := models.MerchantSettingOne(merchantId)
+	date := c.GetString("date")
+	if _, err := time.Parse(conf.BizDateLayout, date); err != nil {
+		date = setting.BizDate(time.Now())
+	}
+	c.Data["imgUrl"] = models.LayoutImages[layout]
+	c.Data["allBars"] = models.BarSiteMap(merchantId, layout)
+	c.Data["date"] = date
+	bars := models.BarDeskMap(merchantId, date)
+	if !c.can(enums.PermViewPhone) {
+		for _, v := range bars {
+			v.CustomerPhone = utils.MaskPhone(v.CustomerPhone)
+		}
+	}
+	c.Data["bars"] = bars
+	c.Data["pass"] = c.curStaff != nil
+	c.Data["paired"] = c.curDevice() != nil
+	c.TplName = "bossbar/index.html"
+}
+
+// Order 订台/修改/转台/标记, date为营业日, 未传时为当前营业日
+func (c *BarController) Order() {
+	c.checkLogin()
+	sites := splitSites(c.GetString("site_name"))
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	merchantId := c.curMerchantId()
+	setting := models.MerchantSettingOne(merchantId)
+	date := c.bizDate(setting, c.GetString("date"))
+	exist := models.BarDeskBySites(merchantId, date, sites)
+	remark := date + " " + strings.Join(sites, ",")
+
+	if to := splitSites(c.GetString("to_site_name")); len(to) > 0 {
+		err := models.BarDeskTransfer(merchantId, exist, to, c.curStaff.Id)
+		c.logResult(conf.LogOperateTypeEdit, "转台 "+remark+" → "+strings.Join(to, ","), err, "转台成功")
+	}
+
+	if status, _ := c.GetInt("status", 0); status > 0 {
+		name, ok := enums.DeskMarkNames[status]
+		if !ok {
+			c.jsonResult(enums.JRCodeFailed, "标记不存在", nil)
+		}
+		promotionId, _ := c.GetInt("promotion_id", 0)
+		if status == enums.DeskDiscount {
+			name += fmt.Sprintf(" 活动%d", promotionId)
+		}
+		err := models.BarDeskMark(merchantId, exist, status, promotionId, c.curStaff.Id)
+		c.logResult(conf.LogOperateTypeEdit, remark+" "+name, err, "标记成功")
+	}
+
+	desk := models.BarDesk{
+		BizDate:       date,
+		CustomerName:  c.GetString("customer_name"),
+		CustomerPhone: c.GetString("customer_phone"),
+		ReserveName:   c.GetString("reserve_name"),
+		Remark:        c.GetString("remark"),
+		StaffId:       c.curStaff.Id,
+	}
+	desk.PartySize, _ = c.GetInt("party_size", 0)
+	desk.Deposit, _ = c.GetFloat("deposit", 0)
+	desk.MinSpend, _ = c.GetFloat("min_spend", 0)
+	if desk.Deposit < 0 || desk.MinSpend < 0 {
+		c.jsonResult(enums.JRCodeFailed, "金额不正确", nil)
+	}
+	if paid, _ := c.GetBool("deposit_paid", false); paid && desk.Deposit > 0 {
+		desk.DepositStatus = enums.DepositPaid
+	}
+	desk.ArriveTime, desk.Duration = c.deskSlot(setting, date, exist)
+	// 与台位当前订台时段重叠视为修改, 只修改重叠的订台; 都不重叠则是新时段
+	var editing []*models.BarDesk
+	for _, v := range exist {
+		if v.Overlaps(desk.ArriveTime, desk.EndTime()) {
+			editing = append(editing, v)
+		}
+	}
+	// 修改时预定人和业绩归属都不变, 只能通过申诉或团队分配修改
+	if len(editing) > 0 {
+		desk.ReserveName, desk.ReserveStaffId = editing[0].ReserveName, editing[0].ReserveStaffId
+	} else {
+		c.reserveStaff(&desk)
+	}
+	remark += " " + desk.ArriveTime.Format(conf.ClockLayout) + " " + desk.CustomerName + " " + utils.MaskPhone(desk.CustomerPhone) + " " + desk.ReserveName + " " + desk.Remark
+	if len(editing) > 0 {
+		// 无权查看手机号时页面上是打码的手机号, 保留原值
+		if !c.can(enums.PermViewPhone) && strings.Contains(desk.CustomerPhone, "*") {
+			desk.CustomerPhone = editing[0].CustomerPhone
+		}
+		err := models.BarDeskEdit(merchantId, editing, desk)
+		c.logResult(conf.LogOperateTypeEdit, remark, err, "修改成功")
+	}
+	if date < setting.BizDate(time.Now()) {
+		c.jsonResult(enums.JRCodeFailed, "不能预定已过去的日期", nil)
+	}
+	err := models.BarDeskOrder(merchantId, sites, desk)
+	for _, site := range sites {
+		models.ReleaseSite(merchantId, site, c.holderId())
+	}
+	c.logResult(conf.LogOperateTypeAdd, remark, err, "订台成功")
+}
+
+// 订台归属的员工, 默认为当前员工; 预定人未填时取员工姓名
+func (c *BarController) reserveStaff(desk *models.BarDesk) {
+	staff := c.curStaff
+	if id, _ := c.GetInt("reserve_staff_id", 0); id > 0 && id != staff.Id {
+		m, err := models.StaffOne(id)
+		if err != nil || m.Merchant.Id != c.curMerchantId() || m.Status != enums.Enabled {
+			c.jsonResult(enums.JRCodeFailed, "预定人不存在", nil)
+		}
+		staff = m
+	}
+	desk.ReserveStaffId = staff.Id
+	if desk.ReserveName == "" {
+		desk.ReserveName = staff.RealName
+	}
+}
+
+type cancelParams struct {
+	SiteName string `json:"site_name"`
+	Date     string `json:"date"`
+}
+
+// Cancel 取消订台
+func (c *BarController) Cancel() {
+	c.checkLogin()
+	var params cancelParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	sites := splitSites(params.SiteName)
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), params.Date)
+	desks := models.BarDeskBySites(merchantId, date, sites)
+	// 一次取消多个台(含拼台整组)需二次验证
+	if len(sites) > 1 || len(desks) > 1 {
+		c.checkTotp()
+	}
+	c.transitResult(desks, enums.DeskStateCancelled, "取消成功", c.waitlistNext(date))
+}
+
+// Confirm 确认待确认的订台
+func (c *BarController) Confirm() {
+	c.transitSites(enums.DeskStateConfirmed, "已确认")
+}
+
+// Seat 客人到店, 到店后不再按爽约释放
+func (c *BarController) Seat() {
+	c.transitSites(enums.DeskStateSeated, "已到店")
+}
+
+// Complete 客人离店, 结算定金并累计客户消费
+func (c *BarController) Complete() {
+	c.transitSites(enums.DeskStateCompleted, "已离店")
+}
+
+// 按台号流转订台状态
+func (c *BarController) transitSites(to int, msg string) {
+	c.checkLogin()
+	var params cancelParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	sites := splitSites(params.SiteName)
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), params.Date)
+	c.transitResult(models.BarDeskBySites(merchantId, date, sites), to, msg, nil)
+}
+
+// 流转订台状态并返回结果, 日志由状态机按流转类型记录
+func (c *BarController) transitResult(desks []*models.BarDesk, to int, msg string, obj interface{}) {
+	if err := models.BarDeskTransit(c.curMerchantId(), desks, to, c.curStaff.Id, c.operatorName()); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, msg, obj)
+}
+
+// Batch 一键清台, 清除所选营业日的订台
+func (c *BarController) Batch() {
+	c.checkPermission(enums.PermBatchClear)
+	c.checkTotp()
+	var params cancelParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), params.Date)
+	_, err := models.BarDeskClear(merchantId, date, c.curStaff.Id, c.operatorName())
+	c.logResultObj(conf.LogOperateTypeCancel, date+" 一键清台", err, "清台成功", c.waitlistNext(date))
+}
+
+// DepositPay 登记已收定金
+func (c *BarController) DepositPay() {
+	c.checkLogin()
+	var params cancelParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	sites := splitSites(params.SiteName)
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), params.Date)
+	desks := models.BarDeskBySites(merchantId, date, sites)
+	err := models.DepositPay(merchantId, desks)
+	remark := date + " " + strings.Join(sites, ",") + " 收定金"
+	if len(desks) > 0 {
+		remark += fmt.Sprintf(" %.2f %s", desks[0].Deposit, desks[0].DepositNo)
+	}
+	c.logResult(conf.LogOperateTypeDeposit, remark, err, "已收定金")
+}
+
+type spendParams struct {
+	SiteName string  `json:"site_name"`
+	Date     string  `json:"date"`
+	Spend    float64 `json:"spend"`
+}
+
+// Spend 登记实际消费, 用于核对最低消费
+func (c *BarController) Spend() {
+	c.checkLogin()
+	var params spendParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	sites := splitSites(params.SiteName)
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), params.Date)
+	err := models.BarDeskSpend(merchantId, models.BarDeskBySites(merchantId, date, sites), params.Spend, c.curStaff.Id)
+	c.logResult(conf.LogOperateTypeEdit, fmt.Sprintf("%s %s 消费 %.2f", date, strings.Join(sites, ","), params.Spend), err, "已登记消费")
+}
+
+// DepositReport 营业日的定金和最低消费对账
+func (c *BarController) DepositReport() {
+	c.checkPermission(enums.PermViewLog)
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), c.GetString("date"))
+	list := models.DepositReport(merchantId, date)
+	if !c.can(enums.PermViewPhone) {
+		for _, v := range list {
+			v.CustomerPhone = utils.MaskPhone(v.CustomerPhone)
+		}
+	}
+	c.jsonResult(enums.JRCodeSucc, "", list)
+}
+
+// Group 拼台订单详情
+func (c *BarController) Group() {
+	c.checkLogin()
+	id, _ := c.GetInt("id", 0)
+	group, desks, err := models.BarGroupOne(c.curMerchantId(), id)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	if !c.can(enums.PermViewPhone) {
+		group.CustomerPhone = utils.MaskPhone(group.CustomerPhone)
+		for _, v := range desks {
+			v.CustomerPhone = utils.MaskPhone(v.CustomerPhone)
+		}
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"group": group,
+		"desks": desks,
+	})
+}
+
+// Suggest 按人数、时段和区域推荐空台或相邻拼台
+func (c *BarController) Suggest() {
+	c.checkLogin()
+	party, _ := c.GetInt("party_size", 0)
+	if party <= 0 {
+		c.jsonResult(enums.JRCodeFailed, "请填写人数", nil)
+	}
+	layout, _ := c.GetInt("type", 0)
+	if layout < 0 || layout >= len(models.LayoutImages) {
+		layout = 0
+	}
+	merchantId := c.curMerchantId()
+	setting := models.MerchantSettingOne(merchantId)
+	date := c.bizDate(setting, c.GetString("date"))
+	start, duration := c.deskSlot(setting, date, nil)
+	end := start.Add(time.Duration(duration) * time.Minute)
+	c.jsonResult(enums.JRCodeSucc, "", models.SuggestSites(merchantId, layout, start, end, party, c.GetString("zone"), 5))
+}
+
+type siteSaveParams struct {
+	Layout   int    `json:"type"`
+	SiteName string `json:"site_name"`
+	Zone     string `json:"zone"`
+	MinCap   int    `json:"min_capacity"`
+	MaxCap   int    `json:"max_capacity"`
+}
+
+// SiteSave 设置台位的区域和可坐人数
+func (c *BarController) SiteSave() {
+	c.checkPermission(enums.PermEditLayout)
+	var params siteSaveParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	err := models.BarSiteSave(c.curMerchantId(), params.Layout, models.BarSite{
+		Name:   params.SiteName,
+		Zone:   params.Zone,
+		MinCap: params.MinCap,
+		MaxCap: params.MaxCap,
+	})
+	remark := fmt.Sprintf("台位%s 区域%s %d~%d人", params.SiteName, params.Zone, params.MinCap, params.MaxCap)
+	c.logResult(conf.LogOperateTypeEdit, remark, err, "保存成功")
+}
+
+type layoutDeleteParams struct {
+	Layout   int    `json:"type"`
+	SiteName string `json:"site_name"`
+}
+
+// LayoutDelete 删除平面图台位, site_name为空时删除整张平面图
+func (c *BarController) LayoutDelete() {
+	c.checkPermission(enums.PermEditLayout)
+	c.checkTotp()
+	var params layoutDeleteParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	if params.Layout < 0 || params.Layout >= len(models.LayoutImages) {
+		c.jsonResult(enums.JRCodeFailed, "平面图不存在", nil)
+	}
+	remark := "删除平面图" + strconv.Itoa(params.Layout)
+	if params.SiteName != "" {
+		remark += " 台位" + params.SiteName
+	}
+	_, err := models.BarSiteDelete(c.curMerchantId(), params.Layout, params.SiteName)
+	c.logResult(conf.LogOperateTypeCancel, remark, err, "删除成功")
+}
+
+// Log 订台日志
+func (c *BarController) Log() {
+	c.checkPermission(enums.PermViewLog)
+	c.Data["barLogs"] = models.BarLogList(c.curMerchantId(), 200)
+	c.TplName = "bossbar/log.html"
+}
+
+// 记录操作日志并返回结果
+func (c *BarController) logResult(logType int, remark string, err error, msg string) {
+	c.logResultObj(logType, remark, err, msg, nil)
+}
+
+func (c *BarController) logResultObj(logType int, remark string, err error, msg string, obj interface{}) {
+	models.AddBarLog(c.curStaff, logType, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, msg, obj)
+}
+
+// 空出台位后提示将台位让给下一位等位客人
+func (c *BarController) waitlistNext(date string) interface{} {
+	next := models.WaitlistNext(c.curMerchantId(), date)
+	if next == nil {
+		return nil
+	}
+	c.maskWaitEntries(next)
+	return map[string]interface{}{"waitlist_next": next}
+}
@@ -1,8 +1,15 @@
 package controllers
 
 import (
+	"BossBar/conf"
 	"BossBar/enums"
+	"BossBar/filters"
 	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/astaxie/beego"
 )
@@ -10,11 +17,256 @@ import (
 type BaseController struct {
 	beego.Controller
 	curMerchant *models.Merchant //当前商户信息
+	curStaff    *models.Staff    //当前登录员工
+}
+
+func (c *BaseController) Prepare() {
+	c.adapterStaffInfo()
+	c.Data["xsrf_token"] = filters.XsrfToken(c.Ctx)
+}
+
+// 获取AuthFilter解析出的当前商户和员工
+func (c *BaseController) adapterStaffInfo() {
+	if m, ok := c.Ctx.Input.GetData(filters.CtxMerchantKey).(*models.Merchant); ok {
+		c.curMerchant = m
+	}
+	if m, ok := c.Ctx.Input.GetData(filters.CtxStaffKey).(*models.Staff); ok {
+		c.curStaff = m
+	}
 }
 
 func (c *BaseController) jsonResult(code enums.JsonResultCode, msg string, obj interface{}) {
-	r := &models.JsonResult{code, msg, obj}
+	r := &models.JsonResult{Code: code, Msg: msg, Obj: obj}
 	c.Data["json"] = r
 	c.ServeJSON()
 	c.StopRun()
 }
+
+// 检查是否登录
+func (c *BaseController) checkLogin() {
+	if c.curStaff == nil {
+		c.jsonResult(enums.JRCode401, "请先登录", nil)
+	}
+}
+
+// 检查是否老板或经理
+func (c *BaseController) checkManager() {
+	c.checkLogin()
+	if !c.curStaff.IsManager() {
+		c.jsonResult(enums.JRCodeFailed, "没有权限", nil)
+	}
+}
+
+// 检查员工权限, 与PermissionFilter双重校验
+func (c *BaseController) checkPermission(perm string) {
+	c.checkLogin()
+	if !c.can(perm) {
+		filters.AbortForbidden(c.Ctx, perm)
+		c.StopRun()
+	}
+}
+
+type totpParams struct {
+	TotpCode string `json:"totp_code"`
+}
+
+// 动态验证码, 依次取请求头X-Totp-Code、json参数、表单参数
+func (c *BaseController) totpCode() string {
+	if code := c.Ctx.Input.Header("X-Totp-Code"); code != "" {
+		return code
+	}
+	var params totpParams
+	if json.Unmarshal(c.Ctx.Input.RequestBody, &params); params.TotpCode != "" {
+		return params.TotpCode
+	}
+	return c.GetString("totp_code")
+}
+
+// 当前员工是否拥有某项权限
+func (c *BaseController) can(perm string) bool {
+	return models.StaffHasPermission(c.curStaff, perm)
+}
+
+// 危险操作须提供动态验证码
+func (c *BaseController) checkTotp() {
+	c.checkLogin()
+	if err := models.StaffVerifyTotp(c.curStaff, c.totpCode()); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+}
+
+// 当前商户ID, 未登录时为0
+func (c *BaseController) curMerchantId() int {
+	if c.curMerchant == nil {
+		return 0
+	}
+	return c.curMerchant.Id
+}
+
+// 解析营业日参数, 未传时为当前营业日
+func (c *BaseController) bizDate(setting *models.MerchantSetting, raw string) string {
+	if raw == "" {
+		return setting.BizDate(time.Now())
+	}
+	if _, err := time.Parse(conf.BizDateLayout, raw); err != nil {
+		c.jsonResult(enums.JRCodeFailed, "日期格式错误", nil)
+	}
+	return raw
+}
+
+// 解析到店时间arrive_time(HH:MM)和时长duration(分钟), 未传时沿用原订台, 新订台默认当前时间或开始营业时间
+func (c *BaseController) deskSlot(setting *models.MerchantSetting, date string, exist []*models.BarDesk) (time.Time, int) {
+	duration, _ := c.GetInt("duration", 0)
+	if duration <= 0 && len(exist) > 0 {
+		duration = exist[0].Duration
+	}
+	if duration <= 0 {
+		duration = setting.Duration()
+	}
+	clock := c.GetString("arrive_time")
+	if clock == "" {
+		if len(exist) > 0 {
+			return exist[0].ArriveTime, duration
+		}
+		if date == setting.BizDate(time.Now()) {
+			return time.Now().Truncate(time.Minute), duration
+		}
+		clock = setting.OpenTime
+	}
+	arrive, err := setting.ArriveAt(date, clock)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	return arrive, duration
+}
+
+// 当前操作人
+func (c *BaseController) operatorName() string {
+	if c.curStaff != nil {
+		return c.curStaff.RealName
+	}
+	return c.Ctx.Input.IP()
+}
+
+// 锁定台位的持有人标识, 按员工id区分同名员工, 未登录时为IP
+func (c *BaseController) holderId() string {
+	if c.curStaff != nil {
+		return "staff:" + strconv.Itoa(c.curStaff.Id)
+	}
+	return "ip:" + c.Ctx.Input.IP()
+}
+
+// 当前已配对的平板, 取cookie或请求头X-Device-Token中的设备凭证
+func (c *BaseController) curDevice() *models.Device {
+	token := c.Ctx.Input.Header("X-Device-Token")
+	if token == "" {
+		token = c.Ctx.GetCookie(filters.DeviceCookieName)
+	}
+	device, err := models.DeviceOneByToken(token)
+	if err != nil {
+		return nil
+	}
+	return device
+}
+
+type loginParams struct {
+	UserName string `json:"user_name"`
+	Pwd      string `json:"pwd"`
+	Pin      string `json:"pin"`
+}
+
+// Login 员工登录, pwd为前端md5后的密码; 已配对平板上可用pin登录
+func (c *BaseController) Login() {
+	var params loginParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	if params.Pin != "" {
+		c.pinLogin(params)
+	}
+	if params.UserName == "" || params.Pwd == "" {
+		c.jsonResult(enums.JRCodeFailed, "用户名和密码不能为空", nil)
+	}
+	staff, err := models.StaffOneByUserName(params.UserName, params.Pwd)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, "用户名或者密码错误", nil)
+	}
+	models.StaffLogin(staff)
+	c.loginSuccess(staff, 0)
+}
+
+func (c *BaseController) pinLogin(params loginParams) {
+	device := c.curDevice()
+	if device == nil {
+		c.jsonResult(enums.JRCodeFailed, "本设备未配对, 请使用密码登录", nil)
+	}
+	staff, err := models.StaffOneByPin(device, params.UserName, params.Pin)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	models.StaffLogin(staff)
+	c.loginSuccess(staff, device.Id)
+}
+
+// 签发token并返回登录信息, deviceId不为0时token绑定该平板
+func (c *BaseController) loginSuccess(staff *models.Staff, deviceId int) {
+	pair, err := utils.GenerateDeviceTokenPair(staff.Merchant.Id, staff.Id, deviceId)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, "登录失败", nil)
+	}
+	filters.SetTokenCookies(c.Ctx, pair)
+	c.jsonResult(enums.JRCodeSucc, "登录成功", map[string]interface{}{
+		"token":              pair.AccessToken,
+		"expires_at":         pair.AccessExpiresAt,
+		"refresh_token":      pair.RefreshToken,
+		"refresh_expires_at": pair.RefreshExpiresAt,
+		"real_name":          staff.RealName,
+		"role":               staff.Role,
+	})
+}
+
+type refreshParams struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Refresh 用refresh token换取新的token对, 未传参数时取cookie
+func (c *BaseController) Refresh() {
+	var params refreshParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	if params.RefreshToken == "" {
+		params.RefreshToken = c.Ctx.GetCookie(filters.RefreshCookieName)
+	}
+	pair, err := utils.RefreshTokenPair(params.RefreshToken)
+	if err != nil {
+		filters.ClearTokenCookies(c.Ctx)
+		c.jsonResult(conf.RequestTokenExpire, "登录已过期, 请重新登录", nil)
+	}
+	filters.SetTokenCookies(c.Ctx, pair)
+	c.jsonResult(enums.JRCodeSucc, "", pair)
+}
+
+// Jwks 公开jwt验证公钥, 供其他服务验证BossBar签发的token
+func (c *BaseController) Jwks() {
+	c.Data["json"] = utils.JWKS()
+	c.ServeJSON()
+}
+
+// XsrfToken 获取xsrf token, 供不渲染模板的客户端使用
+func (c *BaseController) XsrfToken() {
+	c.jsonResult(enums.JRCodeSucc, "", map[string]string{
+		"xsrf_token": filters.XsrfToken(c.Ctx),
+		"header":     filters.XsrfHeaderName,
+	})
+}
+
+// Logout 退出登录, 注销本次登录签发的全部token
+func (c *BaseController) Logout() {
+	token := c.Ctx.GetCookie(filters.TokenCookieName)
+	if auth := c.Ctx.Input.Header("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if claims, err := utils.ParseToken(token); err == nil {
+		utils.RevokeToken(claims)
+		utils.RevokeFamily(claims.Family)
+	}
+	filters.ClearTokenCookies(c.Ctx)
+	c.jsonResult(enums.JRCodeSucc, "已退出", nil)
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"strings"
+)
+
+// CustomerController 客户资料, 按手机号识别老客户
+type CustomerController struct {
+	BaseController
+}
+
+func (c *CustomerController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+type customerParams struct {
+	Phone string   `json:"phone"`
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Notes string   `json:"notes"`
+}
+
+// Lookup 订台时输入手机号自动带出客户资料
+func (c *CustomerController) Lookup() {
+	phone := c.GetString("phone")
+	if len(models.NormalizePhone(phone)) < 7 {
+		c.jsonResult(enums.JRCodeSucc, "", nil)
+	}
+	m, err := models.CustomerOneByPhone(c.curMerchantId(), phone)
+	if err != nil {
+		c.jsonResult(enums.JRCodeSucc, "", nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "", m)
+}
+
+// List 客户列表, 支持按手机号、名字和标签筛选, 没有查看手机号权限时手机号须输入完整
+func (c *CustomerController) List() {
+	viewPhone := c.can(enums.PermViewPhone)
+	list := models.CustomerList(c.curMerchantId(), c.GetString("keyword"), c.GetString("tag"), viewPhone, 200)
+	if !viewPhone {
+		for _, v := range list {
+			v.Phone = utils.MaskPhone(v.Phone)
+		}
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"list": list,
+		"tags": enums.CustomerTags,
+	})
+}
+
+// Save 修改客户的标签和备注
+func (c *CustomerController) Save() {
+	var params customerParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	m, err := models.CustomerSave(c.curMerchantId(), params.Phone, params.Name, params.Tags, params.Notes)
+	remark := "客户资料 " + utils.MaskPhone(params.Phone) + " " + strings.Join(params.Tags, ",")
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeEdit, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "保存成功", m)
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/filters"
+	"BossBar/models"
+	"encoding/json"
+	"net/url"
+)
+
+// DeviceController 吧台平板配对与管理
+type DeviceController struct {
+	BaseController
+}
+
+type deviceParams struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code"`
+	Pwd  string `json:"pwd"`
+	Pin  string `json:"pin"`
+}
+
+func (c *DeviceController) parseParams() *deviceParams {
+	var params deviceParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	return &params
+}
+
+// PairCode 经理生成配对码和二维码链接, 平板扫码或输入配对码
+func (c *DeviceController) PairCode() {
+	c.checkManager()
+	params := c.parseParams()
+	if params.Name == "" {
+		params.Name = "吧台平板"
+	}
+	code, timeout, err := models.DevicePairCode(c.curStaff, params.Name)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"code":       code,
+		"uri":        c.Ctx.Input.Scheme() + "://" + c.Ctx.Request.Host + "/?pair_code=" + url.QueryEscape(code),
+		"expires_in": timeout,
+	})
+}
+
+// Pair 平板提交配对码, 下发设备凭证; 扫码打开首页时由页面提交二维码中的配对码
+func (c *DeviceController) Pair() {
+	code := c.GetString("code")
+	if code == "" {
+		code = c.parseParams().Code
+	}
+	device, token, err := models.DevicePair(code, c.Ctx.Input.IP())
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	filters.SetDeviceCookie(c.Ctx, token)
+	c.jsonResult(enums.JRCodeSucc, "配对成功", map[string]interface{}{
+		"id":           device.Id,
+		"name":         device.Name,
+		"device_token": token,
+	})
+}
+
+// List 商户已配对的平板
+func (c *DeviceController) List() {
+	c.checkManager()
+	c.jsonResult(enums.JRCodeSucc, "", models.DeviceList(c.curMerchantId()))
+}
+
+// Revoke 吊销平板, 该平板上的登录全部失效
+func (c *DeviceController) Revoke() {
+	c.checkManager()
+	params := c.parseParams()
+	if err := models.DeviceRevoke(c.curMerchantId(), params.Id); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "已吊销该设备", nil)
+}
+
+// Pin 员工设置自己的平板登录PIN, 需验证密码
+func (c *DeviceController) Pin() {
+	c.checkLogin()
+	params := c.parseParams()
+	if _, err := models.StaffOneByUserName(c.curStaff.UserName, params.Pwd); err != nil {
+		c.jsonResult(enums.JRCodeFailed, "密码错误", nil)
+	}
+	if err := models.StaffSetPin(c.curStaff, params.Pin); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "PIN设置成功", nil)
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DrinkController 酒水单和订台预订酒水
+type DrinkController struct {
+	BaseController
+}
+
+func (c *DrinkController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+type preOrderParams struct {
+	SiteName string `json:"site_name"`
+	Date     string `json:"date"`
+	DrinkId  int    `json:"drink_id"`
+	Quantity int    `json:"quantity"`
+	Id       int    `json:"id"`
+}
+
+// List 酒水单, available=1时只返回有货的
+func (c *DrinkController) List() {
+	available, _ := c.GetBool("available", false)
+	c.jsonResult(enums.JRCodeSucc, "", models.DrinkList(c.curMerchantId(), available))
+}
+
+// Save 新增或修改酒水, 仅经理
+func (c *DrinkController) Save() {
+	c.checkManager()
+	var m models.Drink
+	json.Unmarshal(c.Ctx.Input.RequestBody, &m)
+	err := models.DrinkSave(c.curMerchantId(), &m)
+	remark := fmt.Sprintf("酒水 %s %s %s %.2f", m.Category, m.Name, m.BottleSize, m.Price)
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeEdit, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "保存成功", m)
+}
+
+// Delete 删除酒水, 仅经理
+func (c *DrinkController) Delete() {
+	c.checkManager()
+	var params preOrderParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	err := models.DrinkDelete(c.curMerchantId(), params.Id)
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeEdit, fmt.Sprintf("删除酒水 %d", params.Id), err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "已删除", nil)
+}
+
+// 按台号取订台, 拼台带上整组
+func (c *DrinkController) bookingDesks(siteName, date string) (string, []string, []*models.BarDesk) {
+	sites := splitSites(siteName)
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	merchantId := c.curMerchantId()
+	date = c.bizDate(models.MerchantSettingOne(merchantId), date)
+	return date, sites, models.BarDeskBySites(merchantId, date, sites)
+}
+
+// PreOrderList 订台预订的酒水
+func (c *DrinkController) PreOrderList() {
+	_, _, desks := c.bookingDesks(c.GetString("site_name"), c.GetString("date"))
+	c.jsonResult(enums.JRCodeSucc, "", models.PreOrderList(c.curMerchantId(), desks))
+}
+
+// PreOrderAdd 为订台预订酒水, 计入最低消费
+func (c *DrinkController) PreOrderAdd() {
+	var params preOrderParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	date, sites, desks := c.bookingDesks(params.SiteName, params.Date)
+	m, err := models.PreOrderAdd(c.curMerchantId(), desks, params.DrinkId, params.Quantity, c.curStaff.Id)
+	remark := date + " " + strings.Join(sites, ",") + " 预订酒水"
+	if m != nil {
+		remark += fmt.Sprintf(" %s x%d %.2f", m.DrinkName, m.Quantity, m.Amount)
+	}
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeAdd, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "预订成功", m)
+}
+
+// PreOrderCancel 取消预订的酒水
+func (c *DrinkController) PreOrderCancel() {
+	var params preOrderParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	m, err := models.PreOrderCancel(c.curMerchantId(), params.Id)
+	remark := fmt.Sprintf("取消预订酒水 %d", params.Id)
+	if m != nil {
+		remark = fmt.Sprintf("%s %s 取消预订酒水 %s x%d", m.BizDate, m.SiteName, m.DrinkName, m.Quantity)
+	}
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeCancel, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "已取消", nil)
+}
+
+// Nightly 营业日报表, 含预订酒水汇总
+func (c *DrinkController) Nightly() {
+	c.checkPermission(enums.PermViewLog)
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), c.GetString("date"))
+	report := models.NightlyReportOf(merchantId, date)
+	if !c.can(enums.PermViewPhone) {
+		for _, v := range report.Bookings {
+			v.CustomerPhone = utils.MaskPhone(v.CustomerPhone)
+		}
+	}
+	c.jsonResult(enums.JRCodeSucc, "", report)
+}
@@ -1,6 +1,9 @@
 package controllers
 
 import (
+	"BossBar/enums"
+	"BossBar/models"
+	"encoding/json"
 	"github.com/astaxie/beego"
 	"net/http"
 )
@@ -11,8 +14,11 @@ func init() {
 	beego.ErrorHandler("501", Error501)
 }
 
+// Error403 与PermissionFilter一致返回json
 func Error403(writer http.ResponseWriter, request *http.Request) {
-	writer.Write([]byte("403 Forbidden"))
+	data, _ := json.Marshal(&models.JsonResult{Code: enums.JRCode403, Msg: "没有权限"})
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.Write(data)
 }
 
 func Error404(writer http.ResponseWriter, request *http.Request) {
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+	"encoding/json"
+	"strings"
+)
+
+type HoldController struct {
+	BaseController
+}
+
+type holdParams struct {
+	SiteName string `json:"site_name"`
+}
+
+func (c *HoldController) parseSites() []string {
+	var params holdParams
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &params); err != nil || params.SiteName == "" {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	return strings.Split(params.SiteName, ",")
+}
+
+// Hold 打开订台弹窗时锁定所选台位, 到期自动释放
+func (c *HoldController) Hold() {
+	sites := c.parseSites()
+	merchantId, holder := c.curMerchantId(), c.holderId()
+	for i, site := range sites {
+		if err := models.HoldSite(merchantId, site, holder, c.operatorName()); err != nil {
+			for _, held := range sites[:i] {
+				models.ReleaseSite(merchantId, held, holder)
+			}
+			c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+		}
+	}
+	c.jsonResult(enums.JRCodeSucc, "锁定成功", models.SiteHoldSeconds())
+}
+
+// Release 关闭弹窗时释放锁定
+func (c *HoldController) Release() {
+	merchantId, holder := c.curMerchantId(), c.holderId()
+	for _, site := range c.parseSites() {
+		models.ReleaseSite(merchantId, site, holder)
+	}
+	c.jsonResult(enums.JRCodeSucc, "释放成功", nil)
+}
+
+// List 当前锁定中的台位
+func (c *HoldController) List() {
+	c.jsonResult(enums.JRCodeSucc, "", models.GetSiteHolds(c.curMerchantId()))
+}
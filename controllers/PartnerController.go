@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/filters"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// PartnerController 合作方管理, 仅老板和经理可用
+type PartnerController struct {
+	BaseController
+}
+
+func (c *PartnerController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkManager()
+}
+
+type partnerParams struct {
+	Id     int    `json:"id"`
+	Name   string `json:"name"`
+	Status int    `json:"status"`
+}
+
+// List 合作方列表
+func (c *PartnerController) List() {
+	c.jsonResult(enums.JRCodeSucc, "", models.PartnerList(c.curMerchantId()))
+}
+
+// Create 新增合作方, app_secret只在此时返回
+func (c *PartnerController) Create() {
+	var params partnerParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	if params.Name == "" {
+		c.jsonResult(enums.JRCodeFailed, "名称不能为空", nil)
+	}
+	m, err := models.PartnerCreate(c.curMerchantId(), params.Name)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "请妥善保存app_secret", map[string]interface{}{
+		"id":         m.Id,
+		"name":       m.Name,
+		"app_key":    m.AppKey,
+		"app_secret": m.AppSecret,
+	})
+}
+
+// Status 启用/停用/删除合作方
+func (c *PartnerController) Status() {
+	var params partnerParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	if params.Status != enums.Enabled && params.Status != enums.Disabled && params.Status != enums.Deleted {
+		c.jsonResult(enums.JRCodeFailed, "状态错误", nil)
+	}
+	if err := models.PartnerSetStatus(c.curMerchantId(), params.Id, params.Status); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "保存成功", nil)
+}
+
+// PartnerApiController 合作方订台接口, 签名由PartnerFilter校验
+type PartnerApiController struct {
+	BaseController
+	curPartner *models.Partner
+}
+
+func (c *PartnerApiController) Prepare() {
+	c.BaseController.Prepare()
+	c.curPartner, _ = c.Ctx.Input.GetData(filters.CtxPartnerKey).(*models.Partner)
+	if c.curPartner == nil {
+		c.jsonResult(enums.JRCode401, "签名错误", nil)
+	}
+}
+
+func (c *PartnerApiController) sites() []string {
+	sites := splitSites(c.GetString("site_name"))
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	return sites
+}
+
+// 记录日志并返回结果, 操作人记为合作方
+func (c *PartnerApiController) logResult(logType int, remark string, err error, msg string) {
+	models.AddBarLogBy(c.curMerchantId(), 0, "合作方:"+c.curPartner.Name, logType, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, msg, nil)
+}
+
+// Desks 营业日已被占用的台位及时段, 不返回订台人信息
+func (c *PartnerApiController) Desks() {
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), c.GetString("date"))
+	c.jsonResult(enums.JRCodeSucc, "", models.BarDeskSlots(merchantId, date))
+}
+
+// Order 订台, 订台为待确认状态, date为营业日, arrive_time为到店时间HH:MM, duration为时长(分钟)
+func (c *PartnerApiController) Order() {
+	sites := c.sites()
+	merchantId := c.curMerchantId()
+	setting := models.MerchantSettingOne(merchantId)
+	date := c.bizDate(setting, c.GetString("date"))
+	if date < setting.BizDate(time.Now()) {
+		c.jsonResult(enums.JRCodeFailed, "不能预定已过去的日期", nil)
+	}
+	desk := models.BarDesk{
+		BizDate:       date,
+		CustomerName:  c.GetString("customer_name"),
+		CustomerPhone: c.GetString("customer_phone"),
+		ReserveName:   c.GetString("reserve_name"),
+		Remark:        c.GetString("remark"),
+		State:         enums.DeskStatePending,
+		PartnerId:     c.curPartner.Id,
+	}
+	desk.PartySize, _ = c.GetInt("party_size", 0)
+	desk.ArriveTime, desk.Duration = c.deskSlot(setting, date, nil)
+	remark := date + " " + strings.Join(sites, ",") + " " + desk.ArriveTime.Format(conf.ClockLayout) + " " + desk.CustomerName + " " + utils.MaskPhone(desk.CustomerPhone) + " " + desk.ReserveName + " " + desk.Remark
+	err := models.BarDeskOrder(merchantId, sites, desk)
+	c.logResult(conf.LogOperateTypeAdd, remark, err, "订台成功")
+}
+
+// Cancel 取消订台, 只能取消本合作方订的台
+func (c *PartnerApiController) Cancel() {
+	sites := c.sites()
+	merchantId := c.curMerchantId()
+	date := c.bizDate(models.MerchantSettingOne(merchantId), c.GetString("date"))
+	desks := models.BarDeskByPartner(merchantId, c.curPartner.Id, date, sites)
+	if len(desks) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "订台信息不存在", nil)
+	}
+	err := models.BarDeskTransit(merchantId, desks, enums.DeskStateCancelled, 0, "合作方:"+c.curPartner.Name)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "取消成功", nil)
+}
@@ -0,0 +1,49 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+	"encoding/json"
+)
+
+// PermissionController 角色权限配置, 老板和经理可查看, 仅老板可修改
+type PermissionController struct {
+	BaseController
+}
+
+func (c *PermissionController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkManager()
+}
+
+// Matrix 当前商户的角色权限表
+func (c *PermissionController) Matrix() {
+	permissions := make([]map[string]string, 0, len(enums.Permissions))
+	for _, perm := range enums.Permissions {
+		permissions = append(permissions, map[string]string{"key": perm, "name": enums.PermissionNames[perm]})
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"permissions": permissions,
+		"roles":       enums.RoleNames,
+		"matrix":      models.RolePermissionMatrix(c.curMerchantId()),
+	})
+}
+
+type permissionParams struct {
+	Matrix map[int][]string `json:"matrix"`
+}
+
+// Save 保存角色权限表
+func (c *PermissionController) Save() {
+	if c.curStaff.Role != enums.RoleOwner {
+		c.jsonResult(enums.JRCode403, "仅老板可修改权限", nil)
+	}
+	var params permissionParams
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &params); err != nil {
+		c.jsonResult(enums.JRCodeFailed, "参数错误", nil)
+	}
+	if err := models.RolePermissionSave(c.curMerchantId(), params.Matrix); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "保存成功", nil)
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"encoding/json"
+	"fmt"
+)
+
+// PromotionController 优惠活动, 标记特惠台时选择
+type PromotionController struct {
+	BaseController
+}
+
+func (c *PromotionController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+// List 优惠活动列表, 传date时只返回当天可用于site_name的活动
+func (c *PromotionController) List() {
+	merchantId := c.curMerchantId()
+	date := c.GetString("date")
+	if date != "" {
+		date = c.bizDate(models.MerchantSettingOne(merchantId), date)
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"list":  models.PromotionList(merchantId, date, splitSites(c.GetString("site_name"))),
+		"types": enums.PromoTypeNames,
+	})
+}
+
+// Save 新增或修改优惠活动, 仅经理
+func (c *PromotionController) Save() {
+	c.checkManager()
+	var m models.Promotion
+	json.Unmarshal(c.Ctx.Input.RequestBody, &m)
+	err := models.PromotionSave(c.curMerchantId(), &m)
+	remark := fmt.Sprintf("优惠活动 %s %s %.2f %s~%s", m.Name, m.TypeName(), m.Value, m.StartDate, m.EndDate)
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeEdit, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "保存成功", m)
+}
+
+// Delete 删除优惠活动, 仅经理
+func (c *PromotionController) Delete() {
+	c.checkManager()
+	var params struct {
+		Id int `json:"id"`
+	}
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	err := models.PromotionDelete(c.curMerchantId(), params.Id)
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeEdit, fmt.Sprintf("删除优惠活动 %d", params.Id), err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "已删除", nil)
+}
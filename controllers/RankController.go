@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+)
+
+// RankController 员工业绩排行
+type RankController struct {
+	BaseController
+}
+
+func (c *RankController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+// List 日榜/月榜, metric为bookings/seated/spend, date为营业日, rebuild=1时经理可从数据库重建
+func (c *RankController) List() {
+	metric := c.GetString("metric", models.RankBookings)
+	if _, ok := models.RankNames[metric]; !ok {
+		c.jsonResult(enums.JRCodeFailed, "排行榜不存在", nil)
+	}
+	date := c.bizDate(models.MerchantSettingOne(c.curMerchantId()), c.GetString("date"))
+	period := date
+	if c.GetString("period") == models.RankMonthly {
+		period = date[:7]
+	}
+	if rebuild, _ := c.GetBool("rebuild", false); rebuild {
+		c.checkManager()
+		if err := models.RankRebuild(c.curMerchantId(), metric, period); err != nil {
+			c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+		}
+	}
+	list, err := models.RankList(c.curMerchantId(), metric, period, 50)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"metric": metric,
+		"name":   models.RankNames[metric],
+		"period": period,
+		"list":   list,
+	})
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"regexp"
+)
+
+// 注册短信验证码场景
+const registerScene = "register"
+
+var phoneRegexp = regexp.MustCompile(`^1\d{10}$`)
+
+// RegisterController 商户自助入驻
+type RegisterController struct {
+	BaseController
+}
+
+type registerParams struct {
+	MerchantName string `json:"merchant_name"`
+	Phone        string `json:"phone"`
+	Code         string `json:"code"`
+	UserName     string `json:"user_name"`
+	RealName     string `json:"real_name"`
+	Pwd          string `json:"pwd"`
+}
+
+func (c *RegisterController) parseParams() *registerParams {
+	var params registerParams
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &params); err != nil {
+		c.jsonResult(enums.JRCodeFailed, "参数错误", nil)
+	}
+	params.Phone = utils.GetPureNumber(params.Phone)
+	if !phoneRegexp.MatchString(params.Phone) {
+		c.jsonResult(enums.JRCodeFailed, "手机号格式错误", nil)
+	}
+	return &params
+}
+
+// Code 发送注册验证码
+func (c *RegisterController) Code() {
+	params := c.parseParams()
+	if err := utils.SendVerifyCode(registerScene, params.Phone); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "验证码已发送", nil)
+}
+
+// Register 校验手机验证码后创建商户及老板账号, 成功后直接登录
+func (c *RegisterController) Register() {
+	params := c.parseParams()
+	if params.MerchantName == "" || params.UserName == "" || params.Pwd == "" {
+		c.jsonResult(enums.JRCodeFailed, "商户名称、用户名和密码不能为空", nil)
+	}
+	if !utils.CheckVerifyCode(registerScene, params.Phone, params.Code) {
+		c.jsonResult(enums.JRCodeFailed, "验证码错误或已过期", nil)
+	}
+	if params.RealName == "" {
+		params.RealName = params.UserName
+	}
+	merchant := &models.Merchant{Name: params.MerchantName, Phone: params.Phone}
+	owner := &models.Staff{UserName: params.UserName, RealName: params.RealName}
+	if err := models.MerchantRegister(merchant, owner, params.Pwd); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.loginSuccess(owner, 0)
+}
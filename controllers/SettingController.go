@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"encoding/json"
+	"fmt"
+)
+
+// SettingController 商户设置, 仅老板和经理
+type SettingController struct {
+	BaseController
+}
+
+func (c *SettingController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkManager()
+}
+
+// 未传的字段保持不变
+type settingParams struct {
+	NoShowGrace *int `json:"no_show_grace"` //爽约宽限(分钟), 0~720, 0为不自动释放
+	RefundHours *int `json:"refund_hours"`  //到店前多少小时取消可退定金, 0~720, 0为默认2小时
+}
+
+// Get 当前商户设置
+func (c *SettingController) Get() {
+	m := models.MerchantSettingOne(c.curMerchantId())
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"no_show_grace": m.NoShowGrace,
+		"refund_hours":  m.RefundHours,
+	})
+}
+
+// Save 修改商户设置
+func (c *SettingController) Save() {
+	var params settingParams
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &params); err != nil {
+		c.jsonResult(enums.JRCodeFailed, "参数错误", nil)
+	}
+	m := models.MerchantSettingOne(c.curMerchantId())
+	var cols []string
+	remark := "商户设置"
+	if params.NoShowGrace != nil {
+		if *params.NoShowGrace < 0 || *params.NoShowGrace > 720 {
+			c.jsonResult(enums.JRCodeFailed, "爽约宽限须在0~720分钟之间, 0为不自动释放", nil)
+		}
+		m.NoShowGrace = *params.NoShowGrace
+		cols = append(cols, "NoShowGrace")
+		if m.NoShowGrace == 0 {
+			remark += " 爽约不自动释放"
+		} else {
+			remark += fmt.Sprintf(" 爽约宽限%d分钟", m.NoShowGrace)
+		}
+	}
+	if params.RefundHours != nil {
+		if *params.RefundHours < 0 || *params.RefundHours > 720 {
+			c.jsonResult(enums.JRCodeFailed, "退定金时限须在0~720小时之间, 0为默认2小时", nil)
+		}
+		m.RefundHours = *params.RefundHours
+		cols = append(cols, "RefundHours")
+		remark += fmt.Sprintf(" 到店前%d小时可退定金", m.RefundHours)
+	}
+	if len(cols) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "没有要修改的设置", nil)
+	}
+	err := models.MerchantSettingSave(m, cols...)
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeEdit, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "保存成功", nil)
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+)
+
+// StaffController 员工账号管理, 仅老板和经理可用
+type StaffController struct {
+	BaseController
+}
+
+func (c *StaffController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkManager()
+}
+
+// List 员工列表
+func (c *StaffController) List() {
+	list := models.StaffList(c.curMerchantId())
+	rows := make([]map[string]interface{}, 0, len(list))
+	for _, v := range list {
+		rows = append(rows, map[string]interface{}{
+			"id":              v.Id,
+			"user_name":       v.UserName,
+			"real_name":       v.RealName,
+			"role":            v.Role,
+			"role_name":       v.RoleName(),
+			"status":          v.Status,
+			"last_login_time": v.LastLoginTime,
+		})
+	}
+	c.jsonResult(enums.JRCodeSucc, "", rows)
+}
+
+type staffParams struct {
+	Id       int    `json:"id"`
+	UserName string `json:"user_name"`
+	RealName string `json:"real_name"`
+	Pwd      string `json:"pwd"`
+	Role     int    `json:"role"`
+	Status   int    `json:"status"`
+}
+
+// Save 新增或修改员工
+func (c *StaffController) Save() {
+	var params staffParams
+	if err := json.Unmarshal(c.Ctx.Input.RequestBody, &params); err != nil {
+		c.jsonResult(enums.JRCodeFailed, "参数错误", nil)
+	}
+	if params.UserName == "" || params.RealName == "" {
+		c.jsonResult(enums.JRCodeFailed, "用户名和姓名不能为空", nil)
+	}
+	m := &models.Staff{Merchant: c.curMerchant, Status: enums.Enabled}
+	if params.Id > 0 {
+		exist, err := models.StaffOne(params.Id)
+		if err != nil || exist.Merchant.Id != c.curMerchantId() || !c.curStaff.CanManage(exist) {
+			c.jsonResult(enums.JRCodeFailed, "员工不存在或没有权限", nil)
+		}
+		m = exist
+		if params.Status == enums.Enabled || params.Status == enums.Disabled {
+			m.Status = params.Status
+		}
+	}
+	m.UserName = params.UserName
+	m.RealName = params.RealName
+	m.Role = params.Role
+	if !c.curStaff.CanManage(m) {
+		c.jsonResult(enums.JRCodeFailed, "没有权限设置该角色", nil)
+	}
+	if err := models.StaffSave(m, params.Pwd); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "保存成功", m.Id)
+}
+
+// Revoke 注销员工的全部登录, 用于平板丢失或离职
+func (c *StaffController) Revoke() {
+	var params staffParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	m, err := models.StaffOne(params.Id)
+	if err != nil || m.Merchant.Id != c.curMerchantId() || !c.curStaff.CanManage(m) {
+		c.jsonResult(enums.JRCodeFailed, "员工不存在或没有权限", nil)
+	}
+	utils.RevokeStaffTokens(m.Id)
+	c.jsonResult(enums.JRCodeSucc, "已注销该员工的全部登录", nil)
+}
+
+// Delete 删除员工
+func (c *StaffController) Delete() {
+	var params staffParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	m, err := models.StaffOne(params.Id)
+	if err != nil || m.Merchant.Id != c.curMerchantId() || !c.curStaff.CanManage(m) || m.Id == c.curStaff.Id {
+		c.jsonResult(enums.JRCodeFailed, "员工不存在或没有权限", nil)
+	}
+	m.Status = enums.Deleted
+	if err = models.StaffSave(m, ""); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	utils.RevokeStaffTokens(m.Id)
+	c.jsonResult(enums.JRCodeSucc, "删除成功", nil)
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"fmt"
+)
+
+// TeamController 团队管理和邀请队友
+type TeamController struct {
+	BaseController
+}
+
+func (c *TeamController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+type teamParams struct {
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+	StaffId  int    `json:"staff_id"`
+	SiteName string `json:"site_name"`
+	Date     string `json:"date"`
+}
+
+func (c *TeamController) parseParams() *teamParams {
+	var params teamParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	return &params
+}
+
+// 当前员工所在的团队
+func (c *TeamController) curTeam() *models.Team {
+	if c.curStaff.TeamId == 0 {
+		c.jsonResult(enums.JRCodeFailed, "你还没有加入团队", nil)
+	}
+	m, err := models.TeamOne(c.curMerchantId(), c.curStaff.TeamId)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	return m
+}
+
+// Index 团队信息和业绩, period=month时为月度业绩
+func (c *TeamController) Index() {
+	if c.curStaff.TeamId == 0 {
+		c.jsonResult(enums.JRCodeSucc, "", nil)
+	}
+	m := c.curTeam()
+	period := c.bizDate(models.MerchantSettingOne(c.curMerchantId()), c.GetString("date"))
+	if c.GetString("period") == models.RankMonthly {
+		period = period[:7]
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"team":       m,
+		"can_manage": m.CanManage(c.curStaff),
+		"stats":      models.TeamStats(m, period),
+	})
+}
+
+// Create 创建团队
+func (c *TeamController) Create() {
+	params := c.parseParams()
+	m, err := models.TeamCreate(c.curStaff, params.Name)
+	c.logResult("创建团队 "+params.Name, err, "创建成功", m)
+}
+
+// Invite 队长生成邀请码
+func (c *TeamController) Invite() {
+	code, timeout, err := models.TeamInvite(c.curStaff, c.curTeam())
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]interface{}{
+		"code":       code,
+		"expires_in": timeout,
+	})
+}
+
+// Join 使用邀请码加入团队
+func (c *TeamController) Join() {
+	params := c.parseParams()
+	m, err := models.TeamJoin(c.curStaff, params.Code)
+	name := ""
+	if m != nil {
+		name = m.Name
+	}
+	c.logResult("加入团队 "+name, err, "已加入团队", m)
+}
+
+// Remove 队长移出队友, staff_id为空时本人退出团队
+func (c *TeamController) Remove() {
+	params := c.parseParams()
+	if params.StaffId == 0 {
+		params.StaffId = c.curStaff.Id
+	}
+	m := c.curTeam()
+	err := models.TeamRemove(c.curStaff, m, params.StaffId)
+	c.logResult(fmt.Sprintf("团队%s 移出员工%d", m.Name, params.StaffId), err, "操作成功", nil)
+}
+
+// Dissolve 解散团队
+func (c *TeamController) Dissolve() {
+	m := c.curTeam()
+	err := models.TeamDissolve(c.curStaff, m)
+	c.logResult("解散团队 "+m.Name, err, "团队已解散", nil)
+}
+
+// Desks 团队成员在营业日的订台
+func (c *TeamController) Desks() {
+	m := c.curTeam()
+	date := c.bizDate(models.MerchantSettingOne(c.curMerchantId()), c.GetString("date"))
+	list := models.TeamDesks(m, date)
+	if !c.can(enums.PermViewPhone) {
+		for _, v := range list {
+			v.CustomerPhone = utils.MaskPhone(v.CustomerPhone)
+		}
+	}
+	c.jsonResult(enums.JRCodeSucc, "", list)
+}
+
+// Assign 队长把团队的订台转给另一名队友
+func (c *TeamController) Assign() {
+	params := c.parseParams()
+	sites := splitSites(params.SiteName)
+	if len(sites) == 0 {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	m := c.curTeam()
+	date := c.bizDate(models.MerchantSettingOne(c.curMerchantId()), params.Date)
+	err := models.TeamAssign(c.curStaff, m, models.BarDeskBySites(c.curMerchantId(), date, sites), params.StaffId)
+	c.logResult(fmt.Sprintf("团队%s %s %s 分配给员工%d", m.Name, date, params.SiteName, params.StaffId), err, "分配成功", nil)
+}
+
+// 记录日志并返回结果
+func (c *TeamController) logResult(remark string, err error, msg string, obj interface{}) {
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeEdit, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, msg, obj)
+}
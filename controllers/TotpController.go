@@ -0,0 +1,54 @@
+package controllers
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+)
+
+// TotpController 动态验证码绑定, 一键清台等危险操作需要
+type TotpController struct {
+	BaseController
+}
+
+func (c *TotpController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+// Enroll 生成密钥和otpauth链接, 前端生成二维码
+func (c *TotpController) Enroll() {
+	secret, uri, err := models.StaffTotpEnroll(c.curStaff)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "", map[string]string{
+		"secret": secret,
+		"uri":    uri,
+	})
+}
+
+// Activate 输入验证器上的动态码完成绑定, 返回恢复码
+func (c *TotpController) Activate() {
+	codes, err := models.StaffTotpActivate(c.curStaff, c.totpCode())
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "绑定成功, 请妥善保存恢复码", codes)
+}
+
+// RecoveryCodes 重新生成恢复码
+func (c *TotpController) RecoveryCodes() {
+	codes, err := models.StaffTotpRecoveryCodes(c.curStaff, c.totpCode())
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "已重新生成恢复码, 旧恢复码作废", codes)
+}
+
+// Disable 解绑
+func (c *TotpController) Disable() {
+	if err := models.StaffTotpDisable(c.curStaff, c.totpCode()); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "已解绑", nil)
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"encoding/json"
+	"fmt"
+)
+
+// WaitlistController 满台时的等位队列
+type WaitlistController struct {
+	BaseController
+}
+
+func (c *WaitlistController) Prepare() {
+	c.BaseController.Prepare()
+	c.checkLogin()
+}
+
+type waitlistParams struct {
+	Id            int    `json:"id"`
+	Date          string `json:"date"`
+	CustomerName  string `json:"customer_name"`
+	CustomerPhone string `json:"customer_phone"`
+	PartySize     int    `json:"party_size"`
+	QuotedWait    int    `json:"quoted_wait"`
+	Remark        string `json:"remark"`
+	SiteName      string `json:"site_name"`
+}
+
+func (c *WaitlistController) parseParams() *waitlistParams {
+	var params waitlistParams
+	json.Unmarshal(c.Ctx.Input.RequestBody, &params)
+	return &params
+}
+
+// 指定id的等位记录, 未传id时取队列中的下一位
+func (c *WaitlistController) entry(params *waitlistParams, setting *models.MerchantSetting) *models.WaitEntry {
+	if params.Id == 0 {
+		m := models.WaitlistNext(c.curMerchantId(), c.bizDate(setting, params.Date))
+		if m == nil {
+			c.jsonResult(enums.JRCodeFailed, "没有等位的客人", nil)
+		}
+		return m
+	}
+	m, err := models.WaitEntryOne(c.curMerchantId(), params.Id)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	return m
+}
+
+// List 营业日的等位队列
+func (c *WaitlistController) List() {
+	date := c.bizDate(models.MerchantSettingOne(c.curMerchantId()), c.GetString("date"))
+	list := models.WaitlistList(c.curMerchantId(), date)
+	c.maskWaitEntries(list...)
+	c.jsonResult(enums.JRCodeSucc, "", list)
+}
+
+// 无权查看手机号时返回打码的手机号
+func (c *BaseController) maskWaitEntries(list ...*models.WaitEntry) {
+	if c.can(enums.PermViewPhone) {
+		return
+	}
+	for _, v := range list {
+		v.CustomerPhone = utils.MaskPhone(v.CustomerPhone)
+	}
+}
+
+// Add 登记等位
+func (c *WaitlistController) Add() {
+	params := c.parseParams()
+	m := &models.WaitEntry{
+		MerchantId:    c.curMerchantId(),
+		BizDate:       c.bizDate(models.MerchantSettingOne(c.curMerchantId()), params.Date),
+		CustomerName:  params.CustomerName,
+		CustomerPhone: params.CustomerPhone,
+		PartySize:     params.PartySize,
+		QuotedWait:    params.QuotedWait,
+		Remark:        params.Remark,
+		StaffId:       c.curStaff.Id,
+	}
+	if err := models.WaitlistAdd(m); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.maskWaitEntries(m)
+	c.jsonResult(enums.JRCodeSucc, "登记成功", m)
+}
+
+// Offer 通知等位客人有台
+func (c *WaitlistController) Offer() {
+	params := c.parseParams()
+	m := c.entry(params, models.MerchantSettingOne(c.curMerchantId()))
+	if err := models.WaitlistOffer(m, params.SiteName, c.curStaff.Id); err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.maskWaitEntries(m)
+	c.jsonResult(enums.JRCodeSucc, "已通知", m)
+}
+
+// Convert 等位客人入座, 生成订台
+func (c *WaitlistController) Convert() {
+	params := c.parseParams()
+	if params.SiteName == "" {
+		c.jsonResult(enums.JRCodeFailed, "台号不能为空", nil)
+	}
+	setting := models.MerchantSettingOne(c.curMerchantId())
+	m := c.entry(params, setting)
+	desk := models.BarDesk{BizDate: m.BizDate, StaffId: c.curStaff.Id}
+	desk.ArriveTime, desk.Duration = c.deskSlot(setting, m.BizDate, nil)
+	err := models.WaitlistConvert(m, params.SiteName, desk)
+	remark := fmt.Sprintf("%s %s 等位入座 %s %s %d人", m.BizDate, params.SiteName, m.CustomerName, utils.MaskPhone(m.CustomerPhone), m.PartySize)
+	models.AddBarLog(c.curStaff, conf.LogOperateTypeAdd, remark, err)
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.maskWaitEntries(m)
+	c.jsonResult(enums.JRCodeSucc, "已入座", m)
+}
+
+// Leave 客人离开
+func (c *WaitlistController) Leave() {
+	params := c.parseParams()
+	m, err := models.WaitEntryOne(c.curMerchantId(), params.Id)
+	if err == nil {
+		err = models.WaitlistLeave(m, c.curStaff.Id)
+	}
+	if err != nil {
+		c.jsonResult(enums.JRCodeFailed, err.Error(), nil)
+	}
+	c.jsonResult(enums.JRCodeSucc, "已移出等位", nil)
+}
@@ -3,9 +3,11 @@ package enums
 type JsonResultCode int
 
 const (
-	JRCodeSucc JsonResultCode = 200
-	JRCode302                 = 302 //跳转至地址
-	JRCode401                 = 401 //未授权访问
+	JRCodeSucc   JsonResultCode = 200
+	JRCode302                   = 302 //跳转至地址
+	JRCode401                   = 401 //未授权访问
+	JRCodeFailed                = 402 //请求失败
+	JRCode403                   = 403 //没有权限
 )
 
 const (
@@ -13,3 +15,122 @@ const (
 	Disabled
 	Enabled
 )
+
+// 台位状态
+const (
+	DeskBooked     = iota + 1 //已订
+	DeskUnmark                //取消标记
+	DeskInvite                //邀请台
+	DeskExperience            //体验台
+	DeskDiscount              //特惠台
+)
+
+// 订台进度, 已有数据中的1为已确认
+const (
+	DeskStateConfirmed = iota + 1 //已确认, 未到店
+	DeskStateSeated               //已到店
+	DeskStateNoShow               //超时未到店, 台位已释放
+	DeskStateCancelled            //已取消
+	DeskStateCompleted            //已离店结束
+	DeskStatePending              //待确认, 合作方渠道的订台需前台确认
+)
+
+var DeskStateNames = map[int]string{
+	DeskStatePending:   "待确认",
+	DeskStateConfirmed: "已确认",
+	DeskStateSeated:    "已到店",
+	DeskStateCompleted: "已离店",
+	DeskStateCancelled: "已取消",
+	DeskStateNoShow:    "爽约",
+}
+
+// DeskStateTransitions 订台状态机 当前状态 => 可流转的状态
+var DeskStateTransitions = map[int][]int{
+	DeskStatePending:   {DeskStateConfirmed, DeskStateCancelled, DeskStateNoShow},
+	DeskStateConfirmed: {DeskStateSeated, DeskStateCancelled, DeskStateNoShow},
+	DeskStateSeated:    {DeskStateCompleted},
+}
+
+// DeskStateLive 占用台位的状态
+var DeskStateLive = []int{DeskStatePending, DeskStateConfirmed, DeskStateSeated}
+
+// 定金状态
+const (
+	DepositNone      = iota //无定金
+	DepositUnpaid           //未付
+	DepositPaid             //已付
+	DepositDeducted         //已抵扣消费
+	DepositRefunded         //已退还
+	DepositForfeited        //已没收
+)
+
+var DepositStatusNames = map[int]string{
+	DepositNone:      "无",
+	DepositUnpaid:    "未付",
+	DepositPaid:      "已付",
+	DepositDeducted:  "已抵扣",
+	DepositRefunded:  "已退还",
+	DepositForfeited: "已没收",
+}
+
+var DeskMarkNames = map[int]string{
+	DeskUnmark:     "取消标记",
+	DeskInvite:     "邀请台",
+	DeskExperience: "体验台",
+	DeskDiscount:   "特惠台",
+}
+
+// 等位状态
+const (
+	WaitWaiting = iota + 1 //等位中
+	WaitOffered            //已通知有台
+	WaitSeated             //已转为订台
+	WaitLeft               //已离开
+)
+
+var WaitStatusNames = map[int]string{
+	WaitWaiting: "等位中",
+	WaitOffered: "已通知",
+	WaitSeated:  "已入座",
+	WaitLeft:    "已离开",
+}
+
+// 客户标签
+const (
+	CustomerTagVIP     = "VIP"
+	CustomerTagRegular = "常客"
+)
+
+var CustomerTags = []string{CustomerTagVIP, CustomerTagRegular}
+
+// 申诉状态
+const (
+	AppealPending  = iota + 1 //待处理
+	AppealApproved            //已通过
+	AppealRejected            //已驳回
+)
+
+var AppealStatusNames = map[int]string{
+	AppealPending:  "待处理",
+	AppealApproved: "已通过",
+	AppealRejected: "已驳回",
+}
+
+// 预订酒水状态
+const (
+	PreOrderActive    = iota + 1 //有效
+	PreOrderCancelled            //已取消
+)
+
+// 优惠活动类型
+const (
+	PromoPercent    = iota + 1 //按比例折扣
+	PromoFreeBottle            //赠送酒水
+	PromoMinSpend              //减免最低消费
+)
+
+var PromoTypeNames = map[int]string{
+	PromoPercent:    "折扣",
+	PromoFreeBottle: "赠酒",
+	PromoMinSpend:   "减低消",
+}
@@ -0,0 +1,35 @@
+package enums
+
+// 操作权限, 商户可按角色配置
+const (
+	PermOrder      = "order"               //订台
+	PermCancel     = "cancel"              //取消订台
+	PermTransfer   = "transfer"            //转台
+	PermMark       = "mark"                //标记
+	PermBatchClear = "batch_clear"         //一键清台
+	PermViewPhone  = "view_customer_phone" //查看客户手机号
+	PermEditLayout = "edit_layout"         //编辑平面图
+	PermViewLog    = "view_logs"           //查看日志
+)
+
+// Permissions 全部权限, 按展示顺序
+var Permissions = []string{PermOrder, PermCancel, PermTransfer, PermMark, PermBatchClear, PermViewPhone, PermEditLayout, PermViewLog}
+
+var PermissionNames = map[string]string{
+	PermOrder:      "订台",
+	PermCancel:     "取消订台",
+	PermTransfer:   "转台",
+	PermMark:       "标记",
+	PermBatchClear: "一键清台",
+	PermViewPhone:  "查看客户手机号",
+	PermEditLayout: "编辑平面图",
+	PermViewLog:    "查看日志",
+}
+
+// DefaultRolePermissions 商户未配置时各角色的默认权限, 老板始终拥有全部权限
+var DefaultRolePermissions = map[int][]string{
+	RoleOwner:    Permissions,
+	RoleManager:  Permissions,
+	RoleHost:     {PermOrder, PermCancel, PermTransfer, PermMark, PermViewPhone, PermViewLog},
+	RolePromoter: {PermOrder, PermMark, PermViewPhone},
+}
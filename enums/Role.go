@@ -0,0 +1,16 @@
+package enums
+
+// 员工角色
+const (
+	RoleOwner    = iota + 1 //老板
+	RoleManager             //经理
+	RoleHost                //迎宾
+	RolePromoter            //订台/营销
+)
+
+var RoleNames = map[int]string{
+	RoleOwner:    "老板",
+	RoleManager:  "经理",
+	RoleHost:     "迎宾",
+	RolePromoter: "营销",
+}
@@ -0,0 +1,134 @@
+package filters
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/context"
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	// TokenCookieName 登录token cookie
+	TokenCookieName = "token"
+	// RefreshCookieName refresh token cookie
+	RefreshCookieName = "refresh_token"
+	// DeviceCookieName 已配对平板的设备凭证cookie
+	DeviceCookieName = "device_token"
+	// CtxMerchantKey 当前商户在Input.Data中的key
+	CtxMerchantKey = "curMerchant"
+	// CtxStaffKey 当前员工在Input.Data中的key
+	CtxStaffKey = "curStaff"
+)
+
+// AuthFilter 解析bearer token或cookie中的jwt, 将当前商户和员工放入上下文
+// 未携带token视为匿名访问, 是否需要登录由控制器决定
+func AuthFilter(ctx *context.Context) {
+	token, bearer := extractToken(ctx)
+	if token == "" {
+		return
+	}
+	// cookie登录的修改类请求未通过xsrf校验时不解析登录, 也不续期, 由XsrfFilter拒绝
+	if !bearer && !xsrfValid(ctx) {
+		return
+	}
+	claims, msg := authClaims(ctx, token, bearer)
+	if claims == nil {
+		abortTokenExpire(ctx, bearer, msg)
+		return
+	}
+
+	// AccessId为空的是商户级token, 不绑定员工
+	if claims.AccessId == 0 {
+		merchant, err := models.MerchantOne(claims.Id)
+		if err != nil || merchant.Status != enums.Enabled {
+			abortTokenExpire(ctx, bearer, "商户不存在或已禁用")
+			return
+		}
+		ctx.Input.SetData(CtxMerchantKey, merchant)
+		return
+	}
+	staff, err := models.StaffOne(claims.AccessId)
+	if err != nil || staff.Status != enums.Enabled || staff.Merchant.Id != claims.Id {
+		abortTokenExpire(ctx, bearer, "账号不存在或已禁用")
+		return
+	}
+	ctx.Input.SetData(CtxStaffKey, staff)
+	ctx.Input.SetData(CtxMerchantKey, staff.Merchant)
+}
+
+// 优先取Authorization: Bearer, 其次取cookie
+func extractToken(ctx *context.Context) (token string, bearer bool) {
+	if auth := ctx.Input.Header("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")), true
+	}
+	return ctx.GetCookie(TokenCookieName), false
+}
+
+// SetTokenCookies 写入登录token cookie
+func SetTokenCookies(ctx *context.Context, pair *utils.TokenPair) {
+	now := time.Now().Unix()
+	ctx.SetCookie(TokenCookieName, pair.AccessToken, pair.RefreshExpiresAt-now, "/", "", false, true)
+	ctx.SetCookie(RefreshCookieName, pair.RefreshToken, pair.RefreshExpiresAt-now, "/", "", false, true)
+}
+
+// ClearTokenCookies 清除登录token cookie
+func ClearTokenCookies(ctx *context.Context) {
+	ctx.SetCookie(TokenCookieName, "", -1)
+	ctx.SetCookie(RefreshCookieName, "", -1)
+}
+
+// SetDeviceCookie 写入设备凭证cookie, 平板长期保存
+func SetDeviceCookie(ctx *context.Context, token string) {
+	ctx.SetCookie(DeviceCookieName, token, 10*365*24*3600, "/", "", false, true)
+}
+
+// 校验token, cookie登录过期时用refresh token无感续期, 失败时返回提示信息
+func authClaims(ctx *context.Context, token string, bearer bool) (*utils.Claims, string) {
+	claims, err := utils.ParseToken(token)
+	if err == nil && claims.Refresh {
+		err = utils.ErrTokenRevoked
+	}
+	if err == nil {
+		return claims, ""
+	}
+	if ve, ok := err.(*jwt.ValidationError); !ok || ve.Errors&jwt.ValidationErrorExpired == 0 {
+		return nil, "登录信息无效, 请重新登录"
+	}
+	if !bearer {
+		if claims = refreshCookieSession(ctx); claims != nil {
+			return claims, ""
+		}
+	}
+	return nil, "登录已过期, 请重新登录"
+}
+
+func refreshCookieSession(ctx *context.Context) *utils.Claims {
+	refreshToken := ctx.GetCookie(RefreshCookieName)
+	if refreshToken == "" {
+		return nil
+	}
+	pair, err := utils.RefreshTokenPair(refreshToken)
+	if err != nil {
+		return nil
+	}
+	SetTokenCookies(ctx, pair)
+	claims, err := utils.ParseToken(pair.AccessToken)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+// token失效: 接口请求返回401, 页面请求清除cookie后按匿名继续
+func abortTokenExpire(ctx *context.Context, bearer bool, msg string) {
+	ClearTokenCookies(ctx)
+	if !bearer && !ctx.Input.IsAjax() {
+		return
+	}
+	ctx.Output.JSON(&models.JsonResult{Code: conf.RequestTokenExpire, Msg: msg}, false, false)
+}
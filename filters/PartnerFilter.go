@@ -0,0 +1,86 @@
+package filters
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+)
+
+// PartnerApiPrefix 合作方接口路径前缀, 使用签名鉴权
+const PartnerApiPrefix = "/partner/api/"
+
+// CtxPartnerKey 当前合作方在Input.Data中的key
+const CtxPartnerKey = "curPartner"
+
+// partnerApiParams 各接口除PartnerSignRequired外允许的参数
+var partnerApiParams = map[string][]string{
+	"desks":  {"date"},
+	"order":  {"site_name", "date", "arrive_time", "duration", "party_size", "customer_name", "customer_phone", "reserve_name", "remark"},
+	"cancel": {"site_name", "date"},
+}
+
+func init() {
+	xsrfExemptPrefixes = append(xsrfExemptPrefixes, PartnerApiPrefix)
+}
+
+// PartnerFilter 校验合作方签名
+// 参数须包含app_key、timestamp(秒)、nonce和sign, sign = models.PartnerSign(除sign外的全部参数, app_secret)
+// 参数不能重复, 不能出现接口未定义的参数
+// timestamp与服务器时间相差不能超过partner::timestamp_skew秒, nonce在此期间内不能重复
+func PartnerFilter(ctx *context.Context) {
+	if ctx.Request.Form == nil {
+		ctx.Request.ParseForm()
+	}
+	api := strings.TrimPrefix(path.Clean(ctx.Request.URL.Path), PartnerApiPrefix)
+	data, err := models.PartnerSignParams(ctx.Request.Form, partnerApiParams[api])
+	if err != nil {
+		abortPartner(ctx, err.Error())
+		return
+	}
+
+	partner, err := models.PartnerOneByKey(data.Get("app_key"))
+	if err != nil {
+		abortPartner(ctx, "app_key无效")
+		return
+	}
+	skew := beego.AppConfig.DefaultInt64("partner::timestamp_skew", 300)
+	timestamp, _ := strconv.ParseInt(data.Get("timestamp"), 10, 64)
+	if diff := time.Now().Unix() - timestamp; diff > skew || diff < -skew {
+		abortPartner(ctx, "timestamp已过期")
+		return
+	}
+	nonce := data.Get("nonce")
+	if len(nonce) < 8 || len(nonce) > 64 {
+		abortPartner(ctx, "nonce长度须为8~64位")
+		return
+	}
+	if !partner.VerifySign(data, ctx.Request.Form.Get("sign")) {
+		abortPartner(ctx, "签名错误")
+		return
+	}
+	// 签名通过后才记录nonce, 防止伪造请求占用nonce
+	if utils.SetNxExCache("partner_nonce:"+partner.AppKey+":"+nonce, "1", int(2*skew)) != nil {
+		abortPartner(ctx, "请求重复")
+		return
+	}
+
+	merchant, err := models.MerchantOne(partner.MerchantId)
+	if err != nil || merchant.Status != enums.Enabled {
+		abortPartner(ctx, "商户不存在或已禁用")
+		return
+	}
+	ctx.Input.SetData(CtxPartnerKey, partner)
+	ctx.Input.SetData(CtxMerchantKey, merchant)
+}
+
+func abortPartner(ctx *context.Context, msg string) {
+	ctx.Output.SetStatus(401)
+	ctx.Output.JSON(&models.JsonResult{Code: enums.JRCode401, Msg: msg}, false, false)
+}
@@ -0,0 +1,74 @@
+package filters
+
+import (
+	"BossBar/enums"
+	"BossBar/models"
+	"BossBar/utils"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/context"
+)
+
+const (
+	// XsrfCookieName xsrf token cookie, 前端读取后放入请求头
+	XsrfCookieName = "xsrf_token"
+	// XsrfHeaderName 提交xsrf token的请求头
+	XsrfHeaderName = "X-Xsrf-Token"
+	// CtxXsrfKey 当前xsrf token在Input.Data中的key, 供模板注入
+	CtxXsrfKey = "xsrfToken"
+)
+
+// 不检查xsrf的路径前缀, 这些接口不使用cookie鉴权
+var xsrfExemptPrefixes []string
+
+// XsrfFilter 双重提交校验: 修改类请求须在请求头或_xsrf参数中带上与cookie一致的token
+// 使用Authorization: Bearer的接口客户端不依赖cookie, 不做检查
+func XsrfFilter(ctx *context.Context) {
+	if !xsrfValid(ctx) {
+		ctx.Output.SetStatus(http.StatusForbidden)
+		ctx.Output.JSON(&models.JsonResult{Code: enums.JRCode403, Msg: "页面已过期, 请刷新后重试"}, false, false)
+	}
+}
+
+// 请求是否通过xsrf校验, AuthFilter据此决定是否续期cookie登录
+func xsrfValid(ctx *context.Context) bool {
+	if !beego.AppConfig.DefaultBool("xsrf::enable", true) {
+		return true
+	}
+	token := XsrfToken(ctx)
+	switch ctx.Input.Method() {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if strings.HasPrefix(ctx.Input.Header("Authorization"), "Bearer ") {
+		return true
+	}
+	for _, prefix := range xsrfExemptPrefixes {
+		if strings.HasPrefix(ctx.Input.URL(), prefix) {
+			return true
+		}
+	}
+	submitted := ctx.Input.Header(XsrfHeaderName)
+	if submitted == "" {
+		submitted = ctx.Input.Query("_xsrf")
+	}
+	return submitted != "" && subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+}
+
+// XsrfToken 获取当前xsrf token, cookie中没有有效token时签发新的
+func XsrfToken(ctx *context.Context) string {
+	if token, ok := ctx.Input.GetData(CtxXsrfKey).(string); ok {
+		return token
+	}
+	token := ctx.GetCookie(XsrfCookieName)
+	if !utils.ValidXsrfToken(token) {
+		token = utils.GenerateXsrfToken()
+		// 前端需读取cookie, 不能设置httponly
+		ctx.SetCookie(XsrfCookieName, token, beego.AppConfig.DefaultInt("xsrf::expire", 30*24*3600), "/", "", false, false)
+	}
+	ctx.Input.SetData(CtxXsrfKey, token)
+	return token
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"BossBar/enums"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// JsonResult 用于返回ajax请求的基类
+type JsonResult struct {
+	Code enums.JsonResultCode `json:"code"`
+	Msg  string               `json:"msg"`
+	Obj  interface{}          `json:"obj"`
+}
+
+func init() {
+	orm.RegisterModel(new(Merchant), new(MerchantSetting), new(Staff), new(BarSite), new(BarDesk), new(BarLog), new(Device), new(RolePermission), new(Partner), new(WaitEntry), new(Customer), new(BarGroup), new(Appeal), new(Team), new(Drink), new(PreOrder), new(Promotion))
+}
+
+// TableName 下面是统一的表名管理
+func TableName(name string) string {
+	prefix := beego.AppConfig.String("db_dt_prefix")
+	return prefix + name
+}
+
+// MerchantTBName 获取 Merchant 对应的表名称
+func MerchantTBName() string {
+	return TableName("merchant")
+}
+
+// MerchantSettingTBName 获取 MerchantSetting 对应的表名称
+func MerchantSettingTBName() string {
+	return TableName("merchant_setting")
+}
+
+// StaffTBName 获取 Staff 对应的表名称
+func StaffTBName() string {
+	return TableName("staff")
+}
+
+// BarSiteTBName 获取 BarSite 对应的表名称
+func BarSiteTBName() string {
+	return TableName("bar_site")
+}
+
+// BarDeskTBName 获取 BarDesk 对应的表名称
+func BarDeskTBName() string {
+	return TableName("bar_desk")
+}
+
+// BarLogTBName 获取 BarLog 对应的表名称
+func BarLogTBName() string {
+	return TableName("bar_log")
+}
+
+// DeviceTBName 获取 Device 对应的表名称
+func DeviceTBName() string {
+	return TableName("device")
+}
+
+// RolePermissionTBName 获取 RolePermission 对应的表名称
+func RolePermissionTBName() string {
+	return TableName("role_permission")
+}
+
+// PartnerTBName 获取 Partner 对应的表名称
+func PartnerTBName() string {
+	return TableName("partner")
+}
+
+// WaitEntryTBName 获取 WaitEntry 对应的表名称
+func WaitEntryTBName() string {
+	return TableName("wait_entry")
+}
+
+// CustomerTBName 获取 Customer 对应的表名称
+func CustomerTBName() string {
+	return TableName("customer")
+}
+
+// BarGroupTBName 获取 BarGroup 对应的表名称
+func BarGroupTBName() string {
+	return TableName("bar_group")
+}
+
+// AppealTBName 获取 Appeal 对应的表名称
+func AppealTBName() string {
+	return TableName("appeal")
+}
+
+// TeamTBName 获取 Team 对应的表名称
+func TeamTBName() string {
+	return TableName("team")
+}
+
+// DrinkTBName 获取 Drink 对应的表名称
+func DrinkTBName() string {
+	return TableName("drink")
+}
+
+// PreOrderTBName 获取 PreOrder 对应的表名称
+func PreOrderTBName() string {
+	return TableName("pre_order")
+}
+
+// PromotionTBName 获取 Promotion 对应的表名称
+func PromotionTBName() string {
+	return TableName("promotion")
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"BossBar/utils"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// 定时提醒, 计时key过期后将提醒推入商户的响铃队列, 由前台轮询播放
+const alarmTimerPrefix = "alarm_timer:"
+
+func init() {
+	utils.RegisterExpireHandler(alarmTimerPrefix, ringExpiredAlarm)
+}
+
+func alarmQueueKey(merchantId int) string {
+	return fmt.Sprintf("alarm_queue:%d", merchantId)
+}
+
+// 计时key过期后值已不可读, 提醒内容另存于hash
+func alarmMsgKey(merchantId int) string {
+	return fmt.Sprintf("alarm_msg:%d", merchantId)
+}
+
+// ScheduleAlarm seconds秒后响铃提醒
+func ScheduleAlarm(merchantId int, msg string, seconds int) error {
+	id := utils.BuildOrderNo()
+	if _, err := utils.HSetCache(alarmMsgKey(merchantId), id, msg); err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%d:%s", alarmTimerPrefix, merchantId, id)
+	return utils.SetExpireCache(key, id, seconds, true)
+}
+
+// PushAlarm 立即响铃提醒
+func PushAlarm(merchantId int, msg string) error {
+	return utils.RPushCache(alarmQueueKey(merchantId), msg)
+}
+
+// PopAlarms 取出待播放的提醒
+func PopAlarms(merchantId int) []string {
+	var msgs []string
+	for {
+		msg, err := utils.LPopCache(alarmQueueKey(merchantId))
+		if err != nil || msg == "" {
+			break
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+func ringExpiredAlarm(key string) {
+	merchantId, id := utils.SplitExpireKey(key, alarmTimerPrefix)
+	if id == "" {
+		return
+	}
+	msg, err := utils.HGetCache(alarmMsgKey(merchantId), id)
+	if err != nil {
+		return
+	}
+	utils.HDelCache(alarmMsgKey(merchantId), id)
+	if err = PushAlarm(merchantId, msg); err != nil {
+		log.Errorf("[listener] push alarm failed, merchant:%d, err:%s", merchantId, err.Error())
+	}
+}
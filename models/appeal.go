@@ -0,0 +1,227 @@
+package models
+
+import (
+	"BossBar/enums"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// Appeal 订台归属申诉, 员工认为客人是自己订的, 由经理审核后改为申诉人
+type Appeal struct {
+	Id           int       `json:"id"`
+	MerchantId   int       `orm:"index" json:"-"`
+	DeskId       int       `orm:"index" json:"-"`
+	GroupId      int       `json:"-"`
+	BizDate      string    `orm:"size(10)" json:"biz_date"`
+	SiteName     string    `orm:"size(255)" json:"site_name"`
+	CustomerName string    `orm:"size(32)" json:"customer_name"`
+	FromName     string    `orm:"size(32)" json:"from_name"` //申诉时的预定人
+	StaffId      int       `orm:"index" json:"staff_id"`     //申诉人
+	StaffName    string    `orm:"size(32)" json:"staff_name"`
+	Evidence     string    `orm:"size(1000)" json:"evidence"` //申诉理由和证据
+	Status       int       `json:"status"`                    //enums.Appeal*
+	ReviewerId   int       `json:"reviewer_id"`
+	ReviewerName string    `orm:"size(32)" json:"reviewer_name"`
+	ReviewRemark string    `orm:"size(255)" json:"review_remark"`
+	ReviewTime   time.Time `orm:"null;type(datetime)" json:"review_time"`
+	CreateTime   time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+}
+
+func (a *Appeal) TableName() string {
+	return AppealTBName()
+}
+
+// StatusName 申诉状态名称
+func (a *Appeal) StatusName() string {
+	return enums.AppealStatusNames[a.Status]
+}
+
+// 申诉的订台: 营业日内该台位展示的一单, 已离店的也可申诉
+func appealDesk(o orm.Ormer, merchantId int, bizDate, siteName string) (*BarDesk, error) {
+	var list []*BarDesk
+	o.QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("biz_date", bizDate).
+		Filter("site_name", siteName).Exclude("state", enums.DeskStateCancelled).All(&list)
+	desk, ok := activeBarDesks(list)[siteName]
+	if !ok {
+		return nil, errors.New("订台信息不存在")
+	}
+	return desk, nil
+}
+
+// AppealCreate 员工对订台归属发起申诉, 同一单同时只能有一个待处理的申诉
+func AppealCreate(staff *Staff, bizDate, siteName, evidence string) (*Appeal, error) {
+	evidence = strings.TrimSpace(evidence)
+	if evidence == "" {
+		return nil, errors.New("请填写申诉理由")
+	}
+	if len([]rune(evidence)) > 1000 {
+		return nil, errors.New("申诉理由不能超过1000字")
+	}
+	o := orm.NewOrm()
+	desk, err := appealDesk(o, staff.Merchant.Id, bizDate, siteName)
+	if err != nil {
+		return nil, err
+	}
+	if desk.ReserveName == staff.RealName {
+		return nil, errors.New("该订台已经是你的")
+	}
+	qs := o.QueryTable(AppealTBName()).Filter("merchant_id", staff.Merchant.Id).Filter("status", enums.AppealPending)
+	if desk.GroupId > 0 {
+		qs = qs.Filter("group_id", desk.GroupId)
+	} else {
+		qs = qs.Filter("desk_id", desk.Id)
+	}
+	if qs.Exist() {
+		return nil, errors.New("该订台已有待处理的申诉")
+	}
+
+	sites := []string{desk.SiteName}
+	if desk.GroupId > 0 {
+		if group, _, err := BarGroupOne(staff.Merchant.Id, desk.GroupId); err == nil {
+			sites = strings.Split(group.Sites, ",")
+		}
+	}
+	m := &Appeal{
+		MerchantId:   staff.Merchant.Id,
+		DeskId:       desk.Id,
+		GroupId:      desk.GroupId,
+		BizDate:      desk.BizDate,
+		SiteName:     strings.Join(sites, ","),
+		CustomerName: desk.CustomerName,
+		FromName:     desk.ReserveName,
+		StaffId:      staff.Id,
+		StaffName:    staff.RealName,
+		Evidence:     evidence,
+		Status:       enums.AppealPending,
+	}
+	if _, err := o.Insert(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AppealOne 获取商户下的申诉
+func AppealOne(merchantId, id int) (*Appeal, error) {
+	m := Appeal{Id: id}
+	if err := orm.NewOrm().Read(&m); err != nil || m.MerchantId != merchantId {
+		return nil, errors.New("申诉不存在")
+	}
+	return &m, nil
+}
+
+// AppealList 申诉列表, staffId大于0时只看本人的
+func AppealList(merchantId, staffId, status int) []*Appeal {
+	var list []*Appeal
+	qs := orm.NewOrm().QueryTable(AppealTBName()).Filter("merchant_id", merchantId)
+	if staffId > 0 {
+		qs = qs.Filter("staff_id", staffId)
+	}
+	if status > 0 {
+		qs = qs.Filter("status", status)
+	}
+	qs.OrderBy("-id").Limit(200).All(&list)
+	return list
+}
+
+// AppealReview 经理审核申诉, 通过时订台的预定人改为申诉人
+func AppealReview(reviewer *Staff, id int, approve bool, remark string) (*Appeal, error) {
+	m, err := AppealOne(reviewer.Merchant.Id, id)
+	if err != nil {
+		return nil, err
+	}
+	if m.Status != enums.AppealPending {
+		return nil, errors.New("申诉已处理")
+	}
+	if m.StaffId == reviewer.Id {
+		return nil, errors.New("不能审核自己的申诉")
+	}
+	m.Status = enums.AppealRejected
+	if approve {
+		m.Status = enums.AppealApproved
+	}
+	m.ReviewerId = reviewer.Id
+	m.ReviewerName = reviewer.RealName
+	m.ReviewRemark = strings.TrimSpace(remark)
+	m.ReviewTime = time.Now()
+
+	o := orm.NewOrm()
+	o.Begin()
+	num, err := o.QueryTable(AppealTBName()).Filter("id", m.Id).Filter("status", enums.AppealPending).Update(orm.Params{
+		"status":        m.Status,
+		"reviewer_id":   m.ReviewerId,
+		"reviewer_name": m.ReviewerName,
+		"review_remark": m.ReviewRemark,
+		"review_time":   m.ReviewTime,
+	})
+	if err == nil && num == 0 {
+		err = errors.New("申诉已处理")
+	}
+	if err == nil && approve {
+		err = appealApply(o, m)
+	}
+	if err != nil {
+		o.Rollback()
+		return nil, err
+	}
+	if err = o.Commit(); err != nil {
+		return nil, err
+	}
+	if approve {
+		rankReset(m.MerchantId, m.BizDate)
+	}
+	return m, nil
+}
+
+// 申诉通过时修改订台的预定人, 按订台当前所在的拼台修改整组, 订台已不存在时返回错误
+func appealApply(o orm.Ormer, m *Appeal) error {
+	desk := BarDesk{Id: m.DeskId}
+	if err := o.Read(&desk); err != nil || desk.MerchantId != m.MerchantId {
+		return errors.New("订台已不存在, 不能通过申诉")
+	}
+	qs := o.QueryTable(BarDeskTBName()).Filter("merchant_id", m.MerchantId)
+	if desk.GroupId > 0 {
+		qs = qs.Filter("group_id", desk.GroupId)
+	} else {
+		qs = qs.Filter("id", desk.Id)
+	}
+	num, err := qs.Update(orm.Params{
+		"reserve_name":     m.StaffName,
+		"reserve_staff_id": m.StaffId,
+		"update_time":      time.Now(),
+	})
+	if err == nil && num == 0 {
+		err = errors.New("订台已不存在, 不能通过申诉")
+	}
+	return err
+}
+
+// 转台后待处理的申诉跟随订台: 指向转台后的第一个台, 拼台和台位改为转台后的
+func appealTransfer(o orm.Ormer, merchantId int, ids []int, deskId, groupId int, sites []string) error {
+	_, err := o.QueryTable(AppealTBName()).Filter("merchant_id", merchantId).Filter("status", enums.AppealPending).Filter("desk_id__in", ids).Update(orm.Params{
+		"desk_id":   deskId,
+		"group_id":  groupId,
+		"site_name": strings.Join(sites, ","),
+	})
+	return err
+}
+
+// AppealLogRemark 申诉日志内容, 同一申诉的日志以编号串起来
+func AppealLogRemark(m *Appeal, action string) string {
+	remark := fmt.Sprintf("申诉#%d %s %s %s %s→%s %s", m.Id, action, m.BizDate, m.SiteName, m.FromName, m.StaffName, m.StatusName())
+	if m.Status == enums.AppealPending {
+		// 日志内容长度有限, 完整理由在申诉记录中
+		evidence := []rune(m.Evidence)
+		if len(evidence) > 100 {
+			evidence = append(evidence[:100], []rune("...")...)
+		}
+		return remark + " 理由:" + string(evidence)
+	}
+	if m.ReviewRemark != "" {
+		remark += " 意见:" + m.ReviewRemark
+	}
+	return remark
+}
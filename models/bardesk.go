@@ -1 +1,536 @@
 package models
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// 台型对应的平面图
+var LayoutImages = []string{
+	"/static/img/bgbasic.png", //基础
+	"/static/img/bg-62.png",   //左上
+	"/static/img/bg-66.png",   //左边
+	"/static/img/bg-81.png",   //右上
+	"/static/img/bg-all.png",  //完整
+}
+
+// DefaultLayoutSites 读取默认平面图(基础台型)的热区模板, 用于新商户入驻
+func DefaultLayoutSites() ([]*BarSite, error) {
+	data, err := ioutil.ReadFile(beego.AppConfig.DefaultString("register::layout_template", "conf/layout_basic.json"))
+	if err != nil {
+		return nil, err
+	}
+	var sites []*BarSite
+	if err = json.Unmarshal(data, &sites); err != nil {
+		return nil, err
+	}
+	return sites, nil
+}
+
+// BarSite 平面图上的台位热区
+type BarSite struct {
+	Id         int
+	MerchantId int    `orm:"index" json:"-"`
+	Layout     int    `json:"-"`
+	Name       string `orm:"size(32)" json:"name"`
+	Shape      string `orm:"size(16)" json:"type"`  //热区形状 rect/circle/poly
+	Coords     string `orm:"size(512)" json:"site"` //热区坐标
+	Class      string `orm:"size(16)" json:"class"` //高亮颜色
+	Zone       string `orm:"size(16)" json:"zone"`  //区域, 未设置时按高亮颜色分区
+	MinCap     int    `json:"min_capacity"`         //最少人数
+	MaxCap     int    `json:"max_capacity"`         //最多人数, 0为默认容量
+}
+
+func (a *BarSite) TableName() string {
+	return BarSiteTBName()
+}
+
+// BarDesk 台位的订台信息, 按营业日和到店时段预定, 同一台位的时段不能重叠
+type BarDesk struct {
+	Id             int       `json:"-"`
+	MerchantId     int       `orm:"index" json:"-"`
+	SiteName       string    `orm:"size(32)" json:"site_name"`
+	BizDate        string    `orm:"size(10)" json:"biz_date"` //营业日 2006-01-02
+	ArriveTime     time.Time `orm:"type(datetime)" json:"-"`  //预计到店时间
+	Duration       int       `json:"duration"`                //预计时长(分钟)
+	Arrive         string    `orm:"-" json:"arrive_time"`     //到店时间 HH:MM, 页面展示用
+	GroupId        int       `orm:"index" json:"group_id"`    //拼台订单, 0为单台
+	PartySize      int       `json:"party_size"`              //人数
+	CustomerName   string    `orm:"size(32)" json:"customer_name"`
+	CustomerPhone  string    `orm:"size(32)" json:"customer_phone"`
+	ReserveName    string    `orm:"size(32)" json:"reserve_name"`
+	ReserveStaffId int       `orm:"index" json:"reserve_staff_id"` //订台的员工, 用于业绩排行
+	PartnerId      int       `orm:"index" json:"partner_id"`       //通过合作方接口订台的合作方, 合作方只能取消自己的订台
+	Remark         string    `orm:"size(255)" json:"remark"`
+	Status         int       `json:"status"`
+	PromotionId    int       `json:"promotion_id"`           //特惠台关联的优惠活动
+	State          int       `orm:"default(1)" json:"state"` //enums.DeskState*
+	SeatedTime     time.Time `orm:"null;type(datetime)" json:"-"`
+	Deposit        float64   `orm:"digits(12);decimals(2)" json:"deposit"`   //定金
+	DepositNo      string    `orm:"size(32)" json:"deposit_no"`              //定金单号, 同一单订的多个台共用
+	DepositStatus  int       `json:"deposit_status"`                         //enums.Deposit*
+	MinSpend       float64   `orm:"digits(12);decimals(2)" json:"min_spend"` //最低消费
+	Spend          float64   `orm:"digits(12);decimals(2)" json:"spend"`     //实际消费
+	StaffId        int       `json:"-"`                                      //最后操作人
+	CreateTime     time.Time `orm:"auto_now_add;type(datetime)" json:"-"`
+	UpdateTime     time.Time `orm:"auto_now;type(datetime)" json:"-"`
+}
+
+func (a *BarDesk) TableName() string {
+	return BarDeskTBName()
+}
+
+func (a *BarDesk) TableIndex() [][]string {
+	return [][]string{
+		{"MerchantId", "BizDate", "SiteName"},
+		{"MerchantId", "SiteName", "ArriveTime"},
+	}
+}
+
+// MaxDeskDuration 订台时长上限(分钟), 检查时段重叠时只回看这么久以内到店的订台
+const MaxDeskDuration = 24 * 60
+
+// EndTime 预计离店时间
+func (a *BarDesk) EndTime() time.Time {
+	return a.ArriveTime.Add(time.Duration(a.Duration) * time.Minute)
+}
+
+// Overlaps 预定时段是否与[start, end)重叠
+func (a *BarDesk) Overlaps(start, end time.Time) bool {
+	return a.ArriveTime.Before(end) && a.EndTime().After(start)
+}
+
+// BarSiteMap 商户某台型下的全部台位 name => site
+func BarSiteMap(merchantId, layout int) map[string]*BarSite {
+	var list []*BarSite
+	orm.NewOrm().QueryTable(BarSiteTBName()).Filter("merchant_id", merchantId).Filter("layout", layout).All(&list)
+	sites := make(map[string]*BarSite, len(list))
+	for _, v := range list {
+		sites[v.Name] = v
+	}
+	return sites
+}
+
+// BarSiteSave 设置台位的区域和容量
+func BarSiteSave(merchantId, layout int, m BarSite) error {
+	if m.MinCap < 0 || m.MaxCap < 0 || (m.MaxCap > 0 && m.MinCap > m.MaxCap) {
+		return errors.New("人数范围不正确")
+	}
+	qs := orm.NewOrm().QueryTable(BarSiteTBName()).Filter("merchant_id", merchantId).Filter("layout", layout).Filter("name", m.Name)
+	if !qs.Exist() {
+		return errors.New("台位不存在")
+	}
+	_, err := qs.Update(orm.Params{
+		"zone":    m.Zone,
+		"min_cap": m.MinCap,
+		"max_cap": m.MaxCap,
+	})
+	return err
+}
+
+// BarSiteDelete 删除平面图上的台位, siteName为空时删除整张平面图
+func BarSiteDelete(merchantId, layout int, siteName string) (int64, error) {
+	qs := orm.NewOrm().QueryTable(BarSiteTBName()).Filter("merchant_id", merchantId).Filter("layout", layout)
+	if siteName != "" {
+		qs = qs.Filter("name", siteName)
+	}
+	return qs.Delete()
+}
+
+// 营业日内每个台位当前展示的订台: 进行中的优先, 其次是最近的下一场, 都没有时取最后结束的一场
+func activeBarDesks(list []*BarDesk) map[string]*BarDesk {
+	now := time.Now()
+	desks := make(map[string]*BarDesk, len(list))
+	rank := func(v *BarDesk) int {
+		switch {
+		case v.Overlaps(now, now.Add(time.Second)):
+			return 0
+		case v.ArriveTime.After(now):
+			return 1
+		}
+		return 2
+	}
+	for _, v := range list {
+		v.Arrive = v.ArriveTime.Format(conf.ClockLayout)
+		cur, ok := desks[v.SiteName]
+		if !ok {
+			desks[v.SiteName] = v
+			continue
+		}
+		r, cr := rank(v), rank(cur)
+		if r < cr || (r == cr && r == 1 && v.ArriveTime.Before(cur.ArriveTime)) || (r == cr && r == 2 && v.ArriveTime.After(cur.ArriveTime)) {
+			desks[v.SiteName] = v
+		}
+	}
+	return desks
+}
+
+// 占用台位的订台, 爽约、取消和结束的不再占用
+func liveBarDesks(o orm.Ormer, merchantId int) orm.QuerySeter {
+	return o.QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("state__in", enums.DeskStateLive)
+}
+
+// BarDeskMap 商户营业日的订台 site_name => desk
+func BarDeskMap(merchantId int, bizDate string) map[string]*BarDesk {
+	var list []*BarDesk
+	liveBarDesks(orm.NewOrm(), merchantId).Filter("biz_date", bizDate).All(&list)
+	return activeBarDesks(list)
+}
+
+// BarDeskSlot 台位已被占用的时段, 不含订台人信息, 供合作方查询
+type BarDeskSlot struct {
+	Arrive   string `json:"arrive_time"`
+	End      string `json:"end_time"`
+	Duration int    `json:"duration"`
+}
+
+// BarDeskSlots 商户营业日内各台位已被占用的时段 site_name => slots, 按到店时间排序
+func BarDeskSlots(merchantId int, bizDate string) map[string][]BarDeskSlot {
+	var list []*BarDesk
+	liveBarDesks(orm.NewOrm(), merchantId).Filter("biz_date", bizDate).OrderBy("arrive_time").All(&list, "SiteName", "ArriveTime", "Duration")
+	slots := make(map[string][]BarDeskSlot)
+	for _, v := range list {
+		slots[v.SiteName] = append(slots[v.SiteName], BarDeskSlot{
+			Arrive:   v.ArriveTime.Format(conf.ClockLayout),
+			End:      v.EndTime().Format(conf.ClockLayout),
+			Duration: v.Duration,
+		})
+	}
+	return slots
+}
+
+// BarDeskBySites 获取营业日内指定台位当前展示的订台, 拼台时带上整组
+func BarDeskBySites(merchantId int, bizDate string, sites []string) []*BarDesk {
+	o := orm.NewOrm()
+	return barDeskBySites(o, liveBarDesks(o, merchantId).Filter("biz_date", bizDate), merchantId, sites)
+}
+
+// BarDeskByPartner 同BarDeskBySites, 只取合作方自己订的台
+func BarDeskByPartner(merchantId, partnerId int, bizDate string, sites []string) []*BarDesk {
+	o := orm.NewOrm()
+	return barDeskBySites(o, liveBarDesks(o, merchantId).Filter("biz_date", bizDate).Filter("partner_id", partnerId), merchantId, sites)
+}
+
+func barDeskBySites(o orm.Ormer, qs orm.QuerySeter, merchantId int, sites []string) []*BarDesk {
+	var list []*BarDesk
+	qs.Filter("site_name__in", sites).All(&list)
+	// 按传入台位的顺序返回, desks[0]为点击的台位
+	active := activeBarDesks(list)
+	desks := make([]*BarDesk, 0, len(sites))
+	for _, site := range sites {
+		if v, ok := active[site]; ok {
+			desks = append(desks, v)
+			delete(active, site)
+		}
+	}
+	return expandBarGroups(o, merchantId, desks)
+}
+
+func barDeskIds(desks []*BarDesk) []int {
+	ids := make([]int, 0, len(desks))
+	for _, v := range desks {
+		ids = append(ids, v.Id)
+	}
+	return ids
+}
+
+// 同一台位的订台操作加锁, 避免并发订出重叠时段
+func lockBarSites(merchantId int, sites []string) (func(), error) {
+	var locked []string
+	unlock := func() {
+		for _, key := range locked {
+			utils.DelCache(key)
+		}
+	}
+	for _, site := range sites {
+		key := fmt.Sprintf("desk_lock:%d:%s", merchantId, site)
+		if err := utils.SetNxExCache(key, "1", 10); err != nil {
+			unlock()
+			return nil, errors.New(site + "正在被操作, 请稍后再试")
+		}
+		locked = append(locked, key)
+	}
+	return unlock, nil
+}
+
+func checkDeskDuration(duration int) error {
+	if duration <= 0 || duration > MaxDeskDuration {
+		return fmt.Errorf("时长须在1~%d分钟之间", MaxDeskDuration)
+	}
+	return nil
+}
+
+// 检查台位在[start, end)内是否已有其他订台
+func checkBarDeskOverlap(o orm.Ormer, merchantId int, site string, start, end time.Time, excludeIds []int) error {
+	var list []*BarDesk
+	qs := liveBarDesks(o, merchantId).Filter("site_name", site).
+		Filter("arrive_time__lt", end).Filter("arrive_time__gt", start.Add(-MaxDeskDuration*time.Minute))
+	if len(excludeIds) > 0 {
+		qs = qs.Exclude("id__in", excludeIds)
+	}
+	qs.All(&list)
+	for _, v := range list {
+		if v.Overlaps(start, end) {
+			return fmt.Errorf("%s在%s~%s已被预定", site, v.ArriveTime.Format(conf.ClockLayout), v.EndTime().Format(conf.ClockLayout))
+		}
+	}
+	return nil
+}
+
+// BarDeskOrder 订台, m须带营业日、到店时间和时长, 与已有时段重叠时返回错误
+func BarDeskOrder(merchantId int, sites []string, m BarDesk) error {
+	unlock, err := lockBarSites(merchantId, sites)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkDeskDuration(m.Duration); err != nil {
+		return err
+	}
+	if err := checkPartySize(merchantId, sites, m.PartySize); err != nil {
+		return err
+	}
+	if m.Deposit > 0 && m.DepositNo == "" {
+		m.DepositNo = utils.BuildOrderNo()
+	}
+	o := orm.NewOrm()
+	o.Begin()
+	if m.GroupId, err = createBarGroup(o, merchantId, sites, &m); err != nil {
+		o.Rollback()
+		return err
+	}
+	for _, site := range sites {
+		if err := checkBarDeskOverlap(o, merchantId, site, m.ArriveTime, m.EndTime(), nil); err != nil {
+			o.Rollback()
+			return err
+		}
+		desk := m
+		desk.MerchantId = merchantId
+		desk.SiteName = site
+		if desk.Status == 0 {
+			desk.Status = enums.DeskBooked
+		}
+		if desk.State == 0 {
+			desk.State = enums.DeskStateConfirmed
+		}
+		if desk.Deposit > 0 && desk.DepositStatus == enums.DepositNone {
+			desk.DepositStatus = enums.DepositUnpaid
+		}
+		if _, err := o.Insert(&desk); err != nil {
+			o.Rollback()
+			return err
+		}
+	}
+	if err := o.Commit(); err != nil {
+		return err
+	}
+	CustomerRecord(merchantId, m.CustomerPhone, m.CustomerName)
+	rankIncr(merchantId, m.ReserveStaffId, m.BizDate, RankBookings, 1)
+	return nil
+}
+
+// BarDeskEdit 修改订台的客户信息和时段, 预定人只能通过申诉或团队分配修改
+func BarDeskEdit(merchantId int, desks []*BarDesk, m BarDesk) error {
+	sites := make([]string, 0, len(desks))
+	for _, v := range desks {
+		sites = append(sites, v.SiteName)
+	}
+	unlock, err := lockBarSites(merchantId, sites)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := checkDeskDuration(m.Duration); err != nil {
+		return err
+	}
+	for _, booking := range splitBookings(desks) {
+		names := make([]string, 0, len(booking))
+		for _, v := range booking {
+			names = append(names, v.SiteName)
+		}
+		if err := checkPartySize(merchantId, names, m.PartySize); err != nil {
+			return err
+		}
+	}
+	o := orm.NewOrm()
+	ids := barDeskIds(desks)
+	for _, site := range sites {
+		if err := checkBarDeskOverlap(o, merchantId, site, m.ArriveTime, m.EndTime(), ids); err != nil {
+			return err
+		}
+	}
+	params := orm.Params{
+		"biz_date":       m.BizDate,
+		"arrive_time":    m.ArriveTime,
+		"duration":       m.Duration,
+		"party_size":     m.PartySize,
+		"customer_name":  m.CustomerName,
+		"customer_phone": m.CustomerPhone,
+		"remark":         m.Remark,
+		"min_spend":      m.MinSpend,
+		"staff_id":       m.StaffId,
+		"update_time":    time.Now(),
+	}
+	// 已付的定金不能再改金额
+	if cur := desks[0]; cur.DepositStatus == enums.DepositNone || cur.DepositStatus == enums.DepositUnpaid {
+		params["deposit"] = m.Deposit
+		params["deposit_status"] = enums.DepositNone
+		if m.Deposit > 0 {
+			params["deposit_status"] = enums.DepositUnpaid
+			if params["deposit_no"] = cur.DepositNo; cur.DepositNo == "" {
+				params["deposit_no"] = utils.BuildOrderNo()
+			}
+		}
+		if m.DepositStatus == enums.DepositPaid && m.Deposit > 0 {
+			params["deposit_status"] = enums.DepositPaid
+		}
+	}
+	if _, err = o.QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("id__in", ids).Update(params); err != nil {
+		return err
+	}
+	for _, booking := range splitBookings(desks) {
+		if err = updateBarGroupCustomer(o, booking[0].GroupId, &m); err != nil {
+			return err
+		}
+	}
+	CustomerRecord(merchantId, m.CustomerPhone, m.CustomerName)
+	return nil
+}
+
+// BarDeskMark 标记订台, 特惠台须关联优惠活动, 改为其他标记时退回活动
+func BarDeskMark(merchantId int, desks []*BarDesk, status, promotionId, staffId int) error {
+	if len(desks) == 0 {
+		return errors.New("订台信息不存在")
+	}
+	if status == enums.DeskUnmark {
+		status = enums.DeskBooked
+	}
+	if status != enums.DeskDiscount {
+		promotionId = 0
+	} else if promotionId == 0 {
+		return errors.New("请选择优惠活动")
+	}
+	o := orm.NewOrm()
+	o.Begin()
+	for _, booking := range splitBookings(desks) {
+		if cur := booking[0].PromotionId; cur != promotionId {
+			if err := promotionRelease(o, merchantId, booking); err != nil {
+				o.Rollback()
+				return err
+			}
+			if promotionId > 0 {
+				if err := promotionApply(o, merchantId, booking, promotionId, staffId); err != nil {
+					o.Rollback()
+					return err
+				}
+			}
+		}
+		_, err := o.QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("id__in", barDeskIds(booking)).Update(orm.Params{
+			"status":       status,
+			"promotion_id": promotionId,
+			"staff_id":     staffId,
+			"update_time":  time.Now(),
+		})
+		if err != nil {
+			o.Rollback()
+			return err
+		}
+	}
+	return o.Commit()
+}
+
+// BarDeskTransfer 转台, 将订台的时段和客户信息移到to台位
+func BarDeskTransfer(merchantId int, desks []*BarDesk, to []string, staffId int) error {
+	if len(desks) == 0 {
+		return errors.New("订台信息不存在")
+	}
+	if len(splitBookings(desks)) > 1 {
+		return errors.New("一次只能转一单订台")
+	}
+	unlock, err := lockBarSites(merchantId, to)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	o := orm.NewOrm()
+	o.Begin()
+	ids := barDeskIds(desks)
+	groupId, err := transferBarGroup(o, merchantId, desks[0], to)
+	if err != nil {
+		o.Rollback()
+		return err
+	}
+	spend := 0.0
+	for _, v := range desks {
+		spend += v.Spend
+	}
+	firstId := desks[0].Id
+	moved := make([]*BarDesk, 0, len(to))
+	for i, site := range to {
+		desk := *desks[0]
+		if i < len(desks) {
+			desk = *desks[i]
+		}
+		if err := checkBarDeskOverlap(o, merchantId, site, desk.ArriveTime, desk.EndTime(), ids); err != nil {
+			o.Rollback()
+			return err
+		}
+		desk.GroupId = groupId
+		desk.SiteName = site
+		desk.StaffId = staffId
+		// 消费只记在第一个台上
+		desk.Spend = 0
+		if i == 0 {
+			desk.Spend = spend
+		}
+		if i < len(desks) {
+			// 原有订台原地改台位, id不变, 申诉等记录仍指向该订台
+			_, err = o.QueryTable(BarDeskTBName()).Filter("id", desk.Id).Update(orm.Params{
+				"site_name":   desk.SiteName,
+				"group_id":    desk.GroupId,
+				"spend":       desk.Spend,
+				"staff_id":    desk.StaffId,
+				"update_time": time.Now(),
+			})
+		} else {
+			desk.Id = 0
+			_, err = o.Insert(&desk)
+		}
+		if err != nil {
+			o.Rollback()
+			return err
+		}
+		moved = append(moved, &desk)
+	}
+	// 转到更少的台位时删除多出的订台
+	if len(desks) > len(to) {
+		if _, err := o.QueryTable(BarDeskTBName()).Filter("id__in", barDeskIds(desks[len(to):])).Delete(); err != nil {
+			o.Rollback()
+			return err
+		}
+	}
+	if err := appealTransfer(o, merchantId, ids, firstId, groupId, to); err != nil {
+		o.Rollback()
+		return err
+	}
+	// 预订的酒水跟随到新台
+	if err := movePreOrders(o, merchantId, desks, to, firstId, groupId); err != nil {
+		o.Rollback()
+		return err
+	}
+	// 特惠台的活动须适用于新台位
+	if err := promotionRecheck(o, merchantId, moved); err != nil {
+		o.Rollback()
+		return err
+	}
+	return o.Commit()
+}
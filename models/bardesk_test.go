@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// 预定时段按[到店时间, 到店时间+时长)计算, 首尾相接不算重叠
+func TestBarDeskOverlaps(t *testing.T) {
+	at := func(clock string) time.Time {
+		v, _ := time.ParseInLocation("2006-01-02 15:04", "2026-05-01 "+clock, time.Local)
+		return v
+	}
+	desk := &BarDesk{ArriveTime: at("20:00"), Duration: 180}
+	cases := []struct {
+		start, end string
+		want       bool
+	}{
+		{"19:00", "20:00", false},
+		{"19:00", "20:01", true},
+		{"21:00", "22:00", true},
+		{"19:00", "23:30", true},
+		{"22:59", "23:30", true},
+		{"23:00", "23:30", false},
+	}
+	for _, c := range cases {
+		if got := desk.Overlaps(at(c.start), at(c.end)); got != c.want {
+			t.Errorf("20:00起180分钟与[%s, %s)重叠 = %v, 期望 %v", c.start, c.end, got, c.want)
+		}
+	}
+}
+
+func TestCheckDeskDuration(t *testing.T) {
+	cases := map[int]bool{-1: false, 0: false, 1: true, 180: true, MaxDeskDuration: true, MaxDeskDuration + 1: false}
+	for duration, ok := range cases {
+		if err := checkDeskDuration(duration); (err == nil) != ok {
+			t.Errorf("checkDeskDuration(%d) = %v", duration, err)
+		}
+	}
+}
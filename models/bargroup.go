@@ -0,0 +1,126 @@
+package models
+
+import (
+	"BossBar/conf"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// BarGroup 拼台订单, 一组客人同时订的多个台位, 取消/转台/标记都按整组操作
+type BarGroup struct {
+	Id            int       `json:"id"`
+	MerchantId    int       `orm:"index" json:"-"`
+	BizDate       string    `orm:"size(10)" json:"biz_date"`
+	Sites         string    `orm:"size(255)" json:"sites"` //组内台位, 逗号分隔
+	CustomerName  string    `orm:"size(32)" json:"customer_name"`
+	CustomerPhone string    `orm:"size(32)" json:"customer_phone"`
+	StaffId       int       `json:"-"`
+	CreateTime    time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+	UpdateTime    time.Time `orm:"auto_now;type(datetime)" json:"-"`
+}
+
+func (a *BarGroup) TableName() string {
+	return BarGroupTBName()
+}
+
+// 订多个台时创建拼台订单, 单台返回0
+func createBarGroup(o orm.Ormer, merchantId int, sites []string, m *BarDesk) (int, error) {
+	if len(sites) < 2 {
+		return 0, nil
+	}
+	group := BarGroup{
+		MerchantId:    merchantId,
+		BizDate:       m.BizDate,
+		Sites:         strings.Join(sites, ","),
+		CustomerName:  m.CustomerName,
+		CustomerPhone: m.CustomerPhone,
+		StaffId:       m.StaffId,
+	}
+	id, err := o.Insert(&group)
+	return int(id), err
+}
+
+// 修改订台时同步拼台订单的客户信息
+func updateBarGroupCustomer(o orm.Ormer, groupId int, m *BarDesk) error {
+	if groupId == 0 {
+		return nil
+	}
+	_, err := o.QueryTable(BarGroupTBName()).Filter("id", groupId).Update(orm.Params{
+		"biz_date":       m.BizDate,
+		"customer_name":  m.CustomerName,
+		"customer_phone": m.CustomerPhone,
+		"update_time":    time.Now(),
+	})
+	return err
+}
+
+// 转台后的拼台订单: 转到多个台时沿用或新建, 转到单台时解散
+func transferBarGroup(o orm.Ormer, merchantId int, desk *BarDesk, to []string) (int, error) {
+	if desk.GroupId == 0 {
+		return createBarGroup(o, merchantId, to, desk)
+	}
+	if len(to) < 2 {
+		_, err := o.QueryTable(BarGroupTBName()).Filter("id", desk.GroupId).Delete()
+		return 0, err
+	}
+	_, err := o.QueryTable(BarGroupTBName()).Filter("id", desk.GroupId).Update(orm.Params{
+		"sites":       strings.Join(to, ","),
+		"update_time": time.Now(),
+	})
+	return desk.GroupId, err
+}
+
+// 选中拼台中的任一台位时带上整组台位
+// 按选中顺序返回, 每个拼台的其余台位按id排在选中的台位之后, desks[0]为点击的台位
+func expandBarGroups(o orm.Ormer, merchantId int, desks []*BarDesk) []*BarDesk {
+	var groupIds []int
+	for _, v := range desks {
+		if v.GroupId > 0 {
+			groupIds = append(groupIds, v.GroupId)
+		}
+	}
+	if len(groupIds) == 0 {
+		return desks
+	}
+	var members []*BarDesk
+	liveBarDesks(o, merchantId).Filter("group_id__in", groupIds).OrderBy("id").All(&members)
+	byGroup := make(map[int][]*BarDesk, len(groupIds))
+	for _, v := range members {
+		v.Arrive = v.ArriveTime.Format(conf.ClockLayout)
+		byGroup[v.GroupId] = append(byGroup[v.GroupId], v)
+	}
+	seen := make(map[int]bool, len(members))
+	expanded := make([]*BarDesk, 0, len(members)+len(desks))
+	for _, v := range desks {
+		if seen[v.Id] {
+			continue
+		}
+		seen[v.Id] = true
+		expanded = append(expanded, v)
+		for _, m := range byGroup[v.GroupId] {
+			if !seen[m.Id] {
+				seen[m.Id] = true
+				expanded = append(expanded, m)
+			}
+		}
+	}
+	return expanded
+}
+
+// BarGroupOne 拼台订单及组内仍有效的订台
+func BarGroupOne(merchantId, id int) (*BarGroup, []*BarDesk, error) {
+	m := BarGroup{Id: id}
+	o := orm.NewOrm()
+	if err := o.Read(&m); err != nil || m.MerchantId != merchantId {
+		return nil, nil, errors.New("拼台订单不存在")
+	}
+	var desks []*BarDesk
+	liveBarDesks(o, merchantId).Filter("group_id", id).OrderBy("id").All(&desks)
+	for _, v := range desks {
+		v.Arrive = v.ArriveTime.Format(conf.ClockLayout)
+	}
+	return &m, desks, nil
+}
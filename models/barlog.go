@@ -0,0 +1,92 @@
+package models
+
+import (
+	"BossBar/conf"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+	log "github.com/sirupsen/logrus"
+)
+
+var LogTypeNames = map[int]string{
+	conf.LogOperateTypeAdd:      "订台",
+	conf.LogOperateTypeReceive:  "接待",
+	conf.LogOperateTypeAppeal:   "申诉",
+	conf.LogOperateTypeNotify:   "通知",
+	conf.LogOperateTypeCancel:   "取消",
+	conf.LogOperateTypeEdit:     "修改",
+	conf.LogOperateTypeConfirm:  "确认",
+	conf.LogOperateTypeComplete: "离店",
+	conf.LogOperateTypeNoShow:   "爽约",
+	conf.LogOperateTypeDeposit:  "定金",
+}
+
+// BarLog 订台操作日志, 操作人取自登录员工
+type BarLog struct {
+	Id           int
+	MerchantId   int       `orm:"index"`
+	Type         int       //conf.LogOperateType*
+	Remark       string    `orm:"size(512)"`
+	Result       int       //conf.OperateFail/OperateSuccess
+	OperaterId   int       `orm:"index"`
+	OperaterName string    `orm:"size(32)"`
+	CreateTime   time.Time `orm:"auto_now_add;type(datetime)"`
+}
+
+func (a *BarLog) TableName() string {
+	return BarLogTBName()
+}
+
+// BarLogView 日志页展示
+type BarLogView struct {
+	Type         string
+	Remark       string
+	Result       string
+	OperaterName string
+	CreateTime   time.Time
+}
+
+// AddBarLog 记录操作日志
+func AddBarLog(operator *Staff, logType int, remark string, err error) {
+	AddBarLogBy(operator.Merchant.Id, operator.Id, operator.RealName, logType, remark, err)
+}
+
+// AddBarLogBy 记录非员工发起的操作日志, 如合作方接口
+func AddBarLogBy(merchantId, operatorId int, operatorName string, logType int, remark string, err error) {
+	m := BarLog{
+		MerchantId:   merchantId,
+		Type:         logType,
+		Remark:       remark,
+		Result:       conf.OperateSuccess,
+		OperaterId:   operatorId,
+		OperaterName: operatorName,
+	}
+	if err != nil {
+		m.Result = conf.OperateFail
+		m.Remark += ", " + err.Error()
+	}
+	if _, e := orm.NewOrm().Insert(&m); e != nil {
+		log.Errorf("AddBarLog failed, merchant:%d, remark:%s, err:%s", m.MerchantId, remark, e.Error())
+	}
+}
+
+// BarLogList 商户最近的操作日志
+func BarLogList(merchantId, limit int) []*BarLogView {
+	var list []*BarLog
+	orm.NewOrm().QueryTable(BarLogTBName()).Filter("merchant_id", merchantId).OrderBy("-id").Limit(limit).All(&list)
+	views := make([]*BarLogView, 0, len(list))
+	for _, v := range list {
+		result := "成功"
+		if v.Result == conf.OperateFail {
+			result = "失败"
+		}
+		views = append(views, &BarLogView{
+			Type:         LogTypeNames[v.Type],
+			Remark:       v.Remark,
+			Result:       result,
+			OperaterName: v.OperaterName,
+			CreateTime:   v.CreateTime,
+		})
+	}
+	return views
+}
@@ -0,0 +1,207 @@
+package models
+
+import (
+	"BossBar/enums"
+	"BossBar/utils"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// Customer 商户的客户, 按手机号区分
+type Customer struct {
+	Id            int       `json:"id"`
+	MerchantId    int       `json:"-"`
+	Phone         string    `orm:"size(32)" json:"phone"`  //只保留数字, 去掉+86
+	Name          string    `orm:"size(32)" json:"name"`   //最近一次订台用的名字
+	Names         string    `orm:"size(255)" json:"names"` //用过的名字, 逗号分隔
+	VisitCount    int       `json:"visit_count"`           //到店次数
+	TotalSpend    float64   `orm:"digits(12);decimals(2)" json:"total_spend"`
+	NoShowCount   int       `json:"no_show_count"`         //爽约次数
+	Tags          string    `orm:"size(128)" json:"tags"`  //标签, 逗号分隔
+	Notes         string    `orm:"size(500)" json:"notes"` //备注
+	LastVisitTime time.Time `orm:"null;type(datetime)" json:"last_visit_time"`
+	CreateTime    time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+	UpdateTime    time.Time `orm:"auto_now;type(datetime)" json:"update_time"`
+}
+
+func (a *Customer) TableName() string {
+	return CustomerTBName()
+}
+
+func (a *Customer) TableUnique() [][]string {
+	return [][]string{
+		{"MerchantId", "Phone"},
+	}
+}
+
+// HasTag 是否有某个标签
+func (a *Customer) HasTag(tag string) bool {
+	for _, v := range splitList(a.Tags) {
+		if v == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// 拆分逗号分隔的列表, 去掉空项
+func splitList(str string) []string {
+	var list []string
+	for _, v := range strings.Split(str, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// 追加到逗号分隔的列表, 已存在时不重复添加
+func appendList(str, item string) string {
+	list := splitList(str)
+	for _, v := range list {
+		if v == item {
+			return str
+		}
+	}
+	return strings.Join(append(list, item), ",")
+}
+
+// NormalizePhone 手机号只保留数字, 去掉国家码86
+func NormalizePhone(phone string) string {
+	phone = utils.GetPureNumber(phone)
+	if len(phone) == 13 && strings.HasPrefix(phone, "86") {
+		phone = phone[2:]
+	}
+	return phone
+}
+
+// 获取或创建客户, 记录新用的名字, 手机号为空时返回nil
+func customerOfPhone(o orm.Ormer, merchantId int, phone, name string) *Customer {
+	phone = NormalizePhone(phone)
+	if phone == "" {
+		return nil
+	}
+	name = strings.TrimSpace(name)
+	m := Customer{MerchantId: merchantId, Phone: phone, Name: name, Names: name}
+	created, _, err := o.ReadOrCreate(&m, "MerchantId", "Phone")
+	if err != nil {
+		return nil
+	}
+	if !created && name != "" && name != m.Name {
+		m.Name = name
+		m.Names = appendList(m.Names, name)
+		o.Update(&m, "Name", "Names", "UpdateTime")
+	}
+	return &m
+}
+
+// CustomerRecord 订台时登记客户
+func CustomerRecord(merchantId int, phone, name string) {
+	customerOfPhone(orm.NewOrm(), merchantId, phone, name)
+}
+
+// CustomerNoShow 客户爽约次数加一
+func CustomerNoShow(merchantId int, phone, name string) {
+	o := orm.NewOrm()
+	if m := customerOfPhone(o, merchantId, phone, name); m != nil {
+		o.QueryTable(CustomerTBName()).Filter("id", m.Id).Update(orm.Params{
+			"no_show_count": orm.ColValue(orm.ColAdd, 1),
+		})
+	}
+}
+
+// CustomerVisit 客户到店次数加一, 达到次数自动标记常客
+func CustomerVisit(merchantId int, phone, name string) {
+	o := orm.NewOrm()
+	m := customerOfPhone(o, merchantId, phone, name)
+	if m == nil {
+		return
+	}
+	m.VisitCount++
+	m.LastVisitTime = time.Now()
+	if n := beego.AppConfig.DefaultInt("customer::regular_visits", 5); n > 0 && m.VisitCount >= n {
+		m.Tags = appendList(m.Tags, enums.CustomerTagRegular)
+	}
+	o.QueryTable(CustomerTBName()).Filter("id", m.Id).Update(orm.Params{
+		"visit_count":     orm.ColValue(orm.ColAdd, 1),
+		"last_visit_time": m.LastVisitTime,
+		"tags":            m.Tags,
+	})
+}
+
+// 订台结束时累计客户消费
+func customerAddSpend(merchantId int, phone string, spend float64) {
+	if spend <= 0 {
+		return
+	}
+	o := orm.NewOrm()
+	if m := customerOfPhone(o, merchantId, phone, ""); m != nil {
+		o.QueryTable(CustomerTBName()).Filter("id", m.Id).Update(orm.Params{
+			"total_spend": orm.ColValue(orm.ColAdd, spend),
+		})
+	}
+}
+
+// CustomerOneByPhone 按手机号查找客户
+func CustomerOneByPhone(merchantId int, phone string) (*Customer, error) {
+	m := Customer{}
+	err := orm.NewOrm().QueryTable(CustomerTBName()).Filter("merchant_id", merchantId).Filter("phone", NormalizePhone(phone)).One(&m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// CustomerList 客户列表, keyword匹配手机号或名字, tag按标签筛选
+// fuzzyPhone为false时手机号须完整匹配, 防止看不到手机号的角色逐位试出号码
+func CustomerList(merchantId int, keyword, tag string, fuzzyPhone bool, limit int) []*Customer {
+	var list []*Customer
+	qs := orm.NewOrm().QueryTable(CustomerTBName()).Filter("merchant_id", merchantId)
+	if keyword = strings.TrimSpace(keyword); keyword != "" {
+		match := orm.NewCondition().Or("names__icontains", keyword)
+		if fuzzyPhone {
+			match = match.Or("phone__contains", keyword)
+		} else if phone := NormalizePhone(keyword); phone != "" {
+			match = match.Or("phone", phone)
+		}
+		qs = qs.SetCond(orm.NewCondition().And("merchant_id", merchantId).AndCond(match))
+	}
+	if tag != "" {
+		qs = qs.Filter("tags__contains", tag)
+	}
+	qs.OrderBy("-visit_count", "-id").Limit(limit).All(&list)
+	return list
+}
+
+// CustomerSave 修改客户的名字、标签和备注, 客户不存在时新建
+func CustomerSave(merchantId int, phone, name string, tags []string, notes string) (*Customer, error) {
+	if len(NormalizePhone(phone)) < 7 {
+		return nil, errors.New("手机号不正确")
+	}
+	o := orm.NewOrm()
+	m := customerOfPhone(o, merchantId, phone, name)
+	if m == nil {
+		return nil, errors.New("保存客户失败")
+	}
+	m.Tags = ""
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			m.Tags = appendList(m.Tags, strings.Replace(tag, ",", "", -1))
+		}
+	}
+	if len(m.Tags) > 128 {
+		return nil, errors.New("标签太多")
+	}
+	m.Notes = strings.TrimSpace(notes)
+	if len([]rune(m.Notes)) > 500 {
+		return nil, errors.New("备注不能超过500字")
+	}
+	if _, err := o.Update(m, "Tags", "Notes", "UpdateTime"); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,146 @@
+package models
+
+import (
+	"BossBar/enums"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// DepositView 定金与最低消费对账, 拼台的多个台合并为一行, 定金和最低消费按单计
+type DepositView struct {
+	DepositNo     string   `json:"deposit_no"`
+	Sites         []string `json:"sites"`
+	CustomerName  string   `json:"customer_name"`
+	CustomerPhone string   `json:"customer_phone"`
+	ArriveTime    string   `json:"arrive_time"`
+	State         int      `json:"state"`
+	Deposit       float64  `json:"deposit"`
+	DepositStatus int      `json:"deposit_status"`
+	StatusName    string   `json:"status_name"`
+	MinSpend      float64  `json:"min_spend"`
+	Spend         float64  `json:"spend"`     //现场消费
+	PreOrder      float64  `json:"pre_order"` //预订酒水, 计入最低消费
+	Shortfall     float64  `json:"shortfall"` //未达最低消费的差额
+	Promotion     string   `json:"promotion"` //特惠台的优惠活动
+	Discount      float64  `json:"discount"`  //折扣金额
+}
+
+// 按订台结束方式结算已付定金:
+// 消费结束抵扣, 爽约没收, 取消时距到店超过退款时限退还, 否则没收
+func depositOutcome(desk *BarDesk, state int, setting *MerchantSetting, now time.Time) int {
+	if desk.DepositStatus != enums.DepositPaid {
+		return desk.DepositStatus
+	}
+	switch state {
+	case enums.DeskStateCompleted:
+		return enums.DepositDeducted
+	case enums.DeskStateCancelled:
+		if !now.Add(setting.RefundBefore()).After(desk.ArriveTime) {
+			return enums.DepositRefunded
+		}
+	}
+	return enums.DepositForfeited
+}
+
+// DepositPay 登记已收定金
+func DepositPay(merchantId int, desks []*BarDesk) error {
+	if len(desks) == 0 {
+		return errors.New("订台信息不存在")
+	}
+	for _, v := range desks {
+		if v.DepositStatus != enums.DepositUnpaid {
+			return errors.New(v.SiteName + "没有待付的定金")
+		}
+	}
+	_, err := orm.NewOrm().QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("id__in", barDeskIds(desks)).
+		Filter("deposit_status", enums.DepositUnpaid).Update(orm.Params{
+		"deposit_status": enums.DepositPaid,
+		"update_time":    time.Now(),
+	})
+	return err
+}
+
+// BarDeskSpend 登记实际消费, 同一单的多个台记在第一个台上
+func BarDeskSpend(merchantId int, desks []*BarDesk, spend float64, staffId int) error {
+	if len(desks) == 0 {
+		return errors.New("订台信息不存在")
+	}
+	if spend < 0 {
+		return errors.New("消费金额不正确")
+	}
+	if len(splitBookings(desks)) > 1 {
+		return errors.New("一次只能登记一单订台的消费")
+	}
+	if desks[0].State != enums.DeskStateSeated {
+		return errors.New("客人未到店")
+	}
+	o := orm.NewOrm()
+	o.Begin()
+	for i, v := range desks {
+		amount := 0.0
+		if i == 0 {
+			amount = spend
+		}
+		if _, err := o.QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("id", v.Id).Update(orm.Params{
+			"spend":       amount,
+			"staff_id":    staffId,
+			"update_time": time.Now(),
+		}); err != nil {
+			o.Rollback()
+			return err
+		}
+	}
+	return o.Commit()
+}
+
+// 营业日每单的定金、消费和预订酒水
+func bookingViews(merchantId int, bizDate string) []*DepositView {
+	var list []*BarDesk
+	orm.NewOrm().QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("biz_date", bizDate).
+		OrderBy("arrive_time", "id").All(&list)
+	preOrders := preOrderTotals(merchantId, bizDate)
+	promotions := promotionMap(merchantId)
+
+	views := make([]*DepositView, 0, len(list))
+	for _, desks := range splitBookings(list) {
+		v := desks[0]
+		view := &DepositView{
+			DepositNo:     v.DepositNo,
+			CustomerName:  v.CustomerName,
+			CustomerPhone: v.CustomerPhone,
+			ArriveTime:    v.ArriveTime.Format("2006-01-02 15:04"),
+			State:         v.State,
+			Deposit:       v.Deposit,
+			DepositStatus: v.DepositStatus,
+			StatusName:    enums.DepositStatusNames[v.DepositStatus],
+			MinSpend:      v.MinSpend,
+			PreOrder:      preOrders[bookingKey(v)],
+		}
+		for _, d := range desks {
+			view.Sites = append(view.Sites, d.SiteName)
+			view.Spend += d.Spend
+		}
+		// 特惠台按活动减免最低消费或打折
+		if p, ok := promotions[v.PromotionId]; ok {
+			view.Promotion = p.Name
+			view.MinSpend, view.Discount = p.effect(view.MinSpend, view.Spend+view.PreOrder)
+		}
+		view.Shortfall = math.Max(0, view.MinSpend-view.Spend-view.PreOrder)
+		views = append(views, view)
+	}
+	return views
+}
+
+// DepositReport 营业日的定金和最低消费对账
+func DepositReport(merchantId int, bizDate string) []*DepositView {
+	views := make([]*DepositView, 0)
+	for _, v := range bookingViews(merchantId, bizDate) {
+		if v.Deposit > 0 || v.MinSpend > 0 {
+			views = append(views, v)
+		}
+	}
+	return views
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"BossBar/enums"
+	"testing"
+	"time"
+)
+
+// 定金结算: 消费抵扣, 提前取消退还, 临时取消或爽约没收
+func TestDepositOutcome(t *testing.T) {
+	arrive := time.Date(2026, 5, 1, 22, 0, 0, 0, time.Local)
+	setting := &MerchantSetting{RefundHours: 2}
+	cases := []struct {
+		name   string
+		status int
+		state  int
+		now    time.Time
+		want   int
+	}{
+		{"无定金", enums.DepositNone, enums.DeskStateCancelled, arrive, enums.DepositNone},
+		{"未付定金", enums.DepositUnpaid, enums.DeskStateNoShow, arrive, enums.DepositUnpaid},
+		{"离店抵扣", enums.DepositPaid, enums.DeskStateCompleted, arrive.Add(3 * time.Hour), enums.DepositDeducted},
+		{"提前取消", enums.DepositPaid, enums.DeskStateCancelled, arrive.Add(-3 * time.Hour), enums.DepositRefunded},
+		{"刚好提前2小时取消", enums.DepositPaid, enums.DeskStateCancelled, arrive.Add(-2 * time.Hour), enums.DepositRefunded},
+		{"临时取消", enums.DepositPaid, enums.DeskStateCancelled, arrive.Add(-time.Hour), enums.DepositForfeited},
+		{"爽约", enums.DepositPaid, enums.DeskStateNoShow, arrive.Add(time.Hour), enums.DepositForfeited},
+	}
+	for _, c := range cases {
+		desk := &BarDesk{ArriveTime: arrive, DepositStatus: c.status}
+		if got := depositOutcome(desk, c.state, setting, c.now); got != c.want {
+			t.Errorf("%s: %s, 期望 %s", c.name, enums.DepositStatusNames[got], enums.DepositStatusNames[c.want])
+		}
+	}
+}
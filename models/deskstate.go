@@ -0,0 +1,186 @@
+package models
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// 进入各状态时记录的日志类型
+var deskStateLogTypes = map[int]int{
+	enums.DeskStateConfirmed: conf.LogOperateTypeConfirm,
+	enums.DeskStateSeated:    conf.LogOperateTypeReceive,
+	enums.DeskStateCompleted: conf.LogOperateTypeComplete,
+	enums.DeskStateCancelled: conf.LogOperateTypeCancel,
+	enums.DeskStateNoShow:    conf.LogOperateTypeNoShow,
+}
+
+// 并发操作时订台状态已被他人修改
+var errDeskStateChanged = errors.New("订台状态已变更, 请刷新后重试")
+
+// CanTransit 订台状态机是否允许从from流转到to
+func CanTransit(from, to int) bool {
+	for _, v := range enums.DeskStateTransitions[from] {
+		if v == to {
+			return true
+		}
+	}
+	return false
+}
+
+// 按订单拆分订台, 拼台的同组台位为一单
+func splitBookings(list []*BarDesk) [][]*BarDesk {
+	index := make(map[int]int)
+	bookings := make([][]*BarDesk, 0)
+	for _, v := range list {
+		key := -v.Id
+		if v.GroupId > 0 {
+			key = v.GroupId
+		}
+		if i, ok := index[key]; ok {
+			bookings[i] = append(bookings[i], v)
+			continue
+		}
+		index[key] = len(bookings)
+		bookings = append(bookings, []*BarDesk{v})
+	}
+	return bookings
+}
+
+// 流转一单订台的状态, 同时处理到店时间、定金结算和客户的到店/消费/爽约统计
+func transitBarDesks(o orm.Ormer, merchantId int, desks []*BarDesk, to, staffId int, now time.Time) error {
+	for _, v := range desks {
+		if !CanTransit(v.State, to) {
+			return fmt.Errorf("%s%s, 不能改为%s", v.SiteName, enums.DeskStateNames[v.State], enums.DeskStateNames[to])
+		}
+	}
+	setting := MerchantSettingOne(merchantId)
+	o.Begin()
+	for _, v := range desks {
+		params := orm.Params{
+			"state":       to,
+			"update_time": now,
+		}
+		if staffId > 0 {
+			params["staff_id"] = staffId
+		}
+		switch to {
+		case enums.DeskStateSeated:
+			params["seated_time"] = now
+		case enums.DeskStateCompleted, enums.DeskStateCancelled, enums.DeskStateNoShow:
+			params["deposit_status"] = depositOutcome(v, to, setting, now)
+		}
+		// 按原状态更新, 多人或多实例同时操作时只有一个成功
+		num, err := o.QueryTable(BarDeskTBName()).Filter("id", v.Id).Filter("state", v.State).Update(params)
+		if err != nil {
+			o.Rollback()
+			return err
+		}
+		if num == 0 {
+			o.Rollback()
+			return errDeskStateChanged
+		}
+	}
+	// 取消的订台退回优惠活动次数
+	if to == enums.DeskStateCancelled {
+		if err := promotionRelease(o, merchantId, desks); err != nil {
+			o.Rollback()
+			return err
+		}
+	}
+	// 取消和爽约的订台, 预订的酒水一并取消, 不再计入报表
+	if to == enums.DeskStateCancelled || to == enums.DeskStateNoShow {
+		if _, err := bookingPreOrders(o, merchantId, desks).Update(orm.Params{"status": enums.PreOrderCancelled}); err != nil {
+			o.Rollback()
+			return err
+		}
+	}
+	if err := o.Commit(); err != nil {
+		return err
+	}
+
+	rankTransit(merchantId, desks, to)
+	first := desks[0]
+	switch to {
+	case enums.DeskStateSeated:
+		CustomerVisit(merchantId, first.CustomerPhone, first.CustomerName)
+	case enums.DeskStateNoShow:
+		CustomerNoShow(merchantId, first.CustomerPhone, first.CustomerName)
+	case enums.DeskStateCompleted:
+		spend := preOrderAmount(merchantId, desks)
+		for _, v := range desks {
+			spend += v.Spend
+		}
+		customerAddSpend(merchantId, first.CustomerPhone, spend)
+	}
+	return nil
+}
+
+// 按目标状态记录流转日志
+func logBarDeskTransit(merchantId int, desks []*BarDesk, to, staffId int, operator string, err error) {
+	sites := make([]string, 0, len(desks))
+	for _, v := range desks {
+		sites = append(sites, v.SiteName)
+	}
+	first := desks[0]
+	remark := fmt.Sprintf("%s %s %s %s %s→%s", first.BizDate, strings.Join(sites, ","), first.ArriveTime.Format(conf.ClockLayout),
+		first.CustomerName, enums.DeskStateNames[first.State], enums.DeskStateNames[to])
+	AddBarLogBy(merchantId, staffId, operator, deskStateLogTypes[to], remark, err)
+}
+
+// BarDeskTransit 流转订台的状态, 只允许状态机中定义的流转, 并按目标状态记录日志
+// 同时选中多单订台时逐单流转, 每单分别统计客户和员工业绩
+func BarDeskTransit(merchantId int, desks []*BarDesk, to, staffId int, operator string) error {
+	if len(desks) == 0 {
+		return errors.New("订台信息不存在")
+	}
+	for _, v := range desks {
+		if !CanTransit(v.State, to) {
+			return fmt.Errorf("%s%s, 不能改为%s", v.SiteName, enums.DeskStateNames[v.State], enums.DeskStateNames[to])
+		}
+	}
+	o := orm.NewOrm()
+	now := time.Now()
+	for _, booking := range splitBookings(desks) {
+		err := transitBarDesks(o, merchantId, booking, to, staffId, now)
+		logBarDeskTransit(merchantId, booking, to, staffId, operator, err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BarDeskClear 一键清台, 结束营业日的全部订台并结算定金:
+// 已到店的离店, 过了到店时间仍未到的按爽约处理, 其余的取消
+func BarDeskClear(merchantId int, bizDate string, staffId int, operator string) (int, error) {
+	var list []*BarDesk
+	o := orm.NewOrm()
+	liveBarDesks(o, merchantId).Filter("biz_date", bizDate).OrderBy("id").All(&list)
+	now := time.Now()
+	total := 0
+	for _, desks := range splitBookings(list) {
+		to := enums.DeskStateCancelled
+		switch {
+		case desks[0].State == enums.DeskStateSeated:
+			to = enums.DeskStateCompleted
+		case desks[0].ArriveTime.Before(now):
+			to = enums.DeskStateNoShow
+		}
+		err := transitBarDesks(o, merchantId, desks, to, staffId, now)
+		logBarDeskTransit(merchantId, desks, to, staffId, operator, err)
+		if err == errDeskStateChanged {
+			continue
+		}
+		if err != nil {
+			return total, err
+		}
+		total += len(desks)
+	}
+	return total, nil
+}
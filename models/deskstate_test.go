@@ -0,0 +1,81 @@
+package models
+
+import (
+	"BossBar/enums"
+	"testing"
+)
+
+// 订台状态机: 只允许登记在DeskStateTransitions中的流转
+func TestCanTransit(t *testing.T) {
+	cases := []struct {
+		from, to int
+		want     bool
+	}{
+		{enums.DeskStatePending, enums.DeskStateConfirmed, true},
+		{enums.DeskStatePending, enums.DeskStateCancelled, true},
+		{enums.DeskStatePending, enums.DeskStateNoShow, true},
+		{enums.DeskStatePending, enums.DeskStateSeated, false},
+		{enums.DeskStateConfirmed, enums.DeskStateSeated, true},
+		{enums.DeskStateConfirmed, enums.DeskStateCancelled, true},
+		{enums.DeskStateConfirmed, enums.DeskStateNoShow, true},
+		{enums.DeskStateConfirmed, enums.DeskStateCompleted, false},
+		{enums.DeskStateSeated, enums.DeskStateCompleted, true},
+		{enums.DeskStateSeated, enums.DeskStateCancelled, false},
+		{enums.DeskStateSeated, enums.DeskStateNoShow, false},
+		{enums.DeskStateCompleted, enums.DeskStateSeated, false},
+		{enums.DeskStateCancelled, enums.DeskStateConfirmed, false},
+		{enums.DeskStateNoShow, enums.DeskStateSeated, false},
+		{0, enums.DeskStateConfirmed, false},
+	}
+	for _, c := range cases {
+		if got := CanTransit(c.from, c.to); got != c.want {
+			t.Errorf("CanTransit(%s, %s) = %v, 期望 %v", enums.DeskStateNames[c.from], enums.DeskStateNames[c.to], got, c.want)
+		}
+	}
+}
+
+// 状态机中的每个状态都有名称, 可流转的目标状态都会记录日志
+func TestDeskStateTransitions(t *testing.T) {
+	for from, list := range enums.DeskStateTransitions {
+		if _, ok := enums.DeskStateNames[from]; !ok {
+			t.Errorf("状态%d没有名称", from)
+		}
+		for _, to := range list {
+			if _, ok := enums.DeskStateNames[to]; !ok {
+				t.Errorf("状态%d没有名称", to)
+			}
+			if _, ok := deskStateLogTypes[to]; !ok {
+				t.Errorf("进入%s时没有日志类型", enums.DeskStateNames[to])
+			}
+		}
+	}
+}
+
+// 拼台的同组台位为一单, 单台各自一单, 保持原顺序
+func TestSplitBookings(t *testing.T) {
+	list := []*BarDesk{
+		{Id: 1, GroupId: 7},
+		{Id: 2},
+		{Id: 3, GroupId: 7},
+		{Id: 4, GroupId: 8},
+		{Id: 5},
+	}
+	want := [][]int{{1, 3}, {2}, {4}, {5}}
+	got := splitBookings(list)
+	if len(got) != len(want) {
+		t.Fatalf("拆分为%d单, 期望%d单", len(got), len(want))
+	}
+	for i, desks := range got {
+		ids := barDeskIds(desks)
+		if len(ids) != len(want[i]) {
+			t.Errorf("第%d单 %v, 期望 %v", i+1, ids, want[i])
+			continue
+		}
+		for j := range ids {
+			if ids[j] != want[i][j] {
+				t.Errorf("第%d单 %v, 期望 %v", i+1, ids, want[i])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package models
+
+import (
+	"BossBar/enums"
+	"BossBar/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// Device 已配对的吧台平板, 凭设备凭证识别所属商户
+type Device struct {
+	Id           int       `json:"id"`
+	MerchantId   int       `json:"-"`
+	Name         string    `orm:"size(32)" json:"name"`
+	TokenHash    string    `orm:"size(64);unique" json:"-"` //设备凭证sha256, 明文只在配对时下发一次
+	PairedBy     int       `json:"paired_by"`
+	Status       int       `orm:"default(1)" json:"status"`
+	CreateTime   time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+	LastSeenTime time.Time `orm:"null;type(datetime)" json:"last_seen_time"`
+}
+
+func (a *Device) TableName() string {
+	return DeviceTBName()
+}
+
+// 待配对信息, 按配对码缓存
+type devicePairing struct {
+	MerchantId int
+	StaffId    int
+	Name       string
+}
+
+const (
+	devicePinMaxFails      = 5   //PIN连续错误次数上限
+	devicePinLockTime      = 900 //PIN错误过多后锁定时长(秒)
+	devicePairIpMaxFails   = 10  //同一IP配对码连续错误次数上限
+	devicePairIpLockTime   = 900 //IP配对错误过多后锁定时长(秒)
+	devicePairCodeMaxFails = 20  //配对码有效期内全站允许的错误次数, 超过后该配对码作废
+)
+
+func devicePairTimeout() int {
+	return beego.AppConfig.DefaultInt("device::pair_timeout", 300)
+}
+
+func devicePairKey(code string) string {
+	return "device_pair:" + code
+}
+
+// 有效期内的配对码集合, 配对失败时给每个配对码累计错误次数
+const devicePairCodesKey = "device_pair_codes"
+
+func devicePairIpFailKey(ip string) string {
+	return "device_pair_fail:ip:" + ip
+}
+
+// 记录一次配对失败: 累计IP错误次数, 并给每个有效配对码累计错误次数, 超过上限的配对码作废
+func devicePairFailed(ip string) {
+	utils.IncrByCache(devicePairIpFailKey(ip), 1)
+	utils.ExpireCache(devicePairIpFailKey(ip), devicePairIpLockTime)
+	codes, _ := utils.SMembersCache(devicePairCodesKey)
+	for _, code := range codes {
+		if exists, _ := utils.ExistsCache(devicePairKey(code)); !exists {
+			utils.SRemCache(devicePairCodesKey, code)
+			continue
+		}
+		failKey := devicePairKey(code) + ":fail"
+		n, _ := utils.IncrByCache(failKey, 1)
+		utils.ExpireCache(failKey, int64(devicePairTimeout()))
+		if n >= devicePairCodeMaxFails {
+			utils.DelCache(devicePairKey(code))
+			utils.SRemCache(devicePairCodesKey, code)
+		}
+	}
+}
+
+func hashDeviceToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DevicePairCode 经理生成配对码, 平板在有效期内输入或扫码完成配对
+func DevicePairCode(operator *Staff, name string) (string, int, error) {
+	timeout := devicePairTimeout()
+	pairing := devicePairing{MerchantId: operator.Merchant.Id, StaffId: operator.Id, Name: name}
+	for i := 0; i < 3; i++ {
+		code := utils.SecureRandomNumString(6)
+		if exists, _ := utils.ExistsCache(devicePairKey(code)); exists {
+			continue
+		}
+		if err := utils.SetCache(devicePairKey(code), pairing, timeout); err != nil {
+			return "", 0, err
+		}
+		utils.SAddCache(devicePairCodesKey, code)
+		return code, timeout, nil
+	}
+	return "", 0, errors.New("生成配对码失败, 请重试")
+}
+
+// DevicePair 使用配对码登记设备, 返回设备凭证明文
+// 同一IP错误过多时锁定, 配对码在有效期内累计错误过多时作废, 防止穷举配对码
+func DevicePair(code, ip string) (*Device, string, error) {
+	fails, _ := utils.GetPureCache(devicePairIpFailKey(ip))
+	if n, _ := strconv.Atoi(fails); n >= devicePairIpMaxFails {
+		return nil, "", errors.New("配对码错误次数过多, 请稍后再试")
+	}
+	var pairing devicePairing
+	if code == "" || utils.GetCache(devicePairKey(code), &pairing) != nil {
+		devicePairFailed(ip)
+		return nil, "", errors.New("配对码错误或已过期")
+	}
+	// 配对码只能使用一次
+	if utils.SetNxExCache(devicePairKey(code)+":used", "1", devicePairTimeout()) != nil {
+		return nil, "", errors.New("配对码已使用")
+	}
+	utils.DelCache(devicePairKey(code))
+	utils.SRemCache(devicePairCodesKey, code)
+
+	token, err := randomHex(32)
+	if err != nil {
+		return nil, "", err
+	}
+	m := &Device{
+		MerchantId: pairing.MerchantId,
+		Name:       pairing.Name,
+		TokenHash:  hashDeviceToken(token),
+		PairedBy:   pairing.StaffId,
+		Status:     enums.Enabled,
+	}
+	if _, err := orm.NewOrm().Insert(m); err != nil {
+		return nil, "", err
+	}
+	return m, token, nil
+}
+
+// DeviceOneByToken 根据设备凭证获取已启用的设备
+func DeviceOneByToken(token string) (*Device, error) {
+	if token == "" {
+		return nil, orm.ErrNoRows
+	}
+	m := Device{}
+	err := orm.NewOrm().QueryTable(DeviceTBName()).Filter("token_hash", hashDeviceToken(token)).Filter("status", enums.Enabled).One(&m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// DeviceList 商户下的设备列表
+func DeviceList(merchantId int) []*Device {
+	var list []*Device
+	orm.NewOrm().QueryTable(DeviceTBName()).Filter("merchant_id", merchantId).Filter("status", enums.Enabled).OrderBy("-id").All(&list)
+	return list
+}
+
+// DeviceRevoke 吊销设备凭证, 并注销该设备上的全部登录
+func DeviceRevoke(merchantId, id int) error {
+	m := Device{Id: id}
+	o := orm.NewOrm()
+	if err := o.Read(&m); err != nil || m.MerchantId != merchantId || m.Status != enums.Enabled {
+		return errors.New("设备不存在")
+	}
+	m.Status = enums.Disabled
+	if _, err := o.Update(&m, "Status"); err != nil {
+		return err
+	}
+	utils.RevokeDeviceTokens(m.Id)
+	return nil
+}
+
+func devicePinFailKey(deviceId int, username string) string {
+	return fmt.Sprintf("device_pin_fail:%d:%s", deviceId, username)
+}
+
+// StaffOneByPin 在已配对设备上用PIN登录, 仅限设备所属商户的员工
+func StaffOneByPin(device *Device, username, pin string) (*Staff, error) {
+	failKey := devicePinFailKey(device.Id, username)
+	fails, _ := utils.GetPureCache(failKey)
+	if n, _ := strconv.Atoi(fails); n >= devicePinMaxFails {
+		return nil, errors.New("PIN错误次数过多, 请稍后再试或使用密码登录")
+	}
+	m := Staff{}
+	err := orm.NewOrm().QueryTable(StaffTBName()).Filter("user_name", username).Filter("merchant_id", device.MerchantId).RelatedSel().One(&m)
+	if err != nil || m.Pin == "" {
+		utils.CheckDummyPassword(pin)
+	} else {
+		if ok, _ := utils.CheckPassword(m.Pin, pin); ok {
+			if m.Status != enums.Enabled {
+				return nil, errors.New("账号已禁用")
+			}
+			utils.DelCache(failKey)
+			device.LastSeenTime = time.Now()
+			orm.NewOrm().Update(device, "LastSeenTime")
+			return &m, nil
+		}
+	}
+	utils.IncrByCache(failKey, 1)
+	utils.ExpireCache(failKey, devicePinLockTime)
+	return nil, errors.New("用户名或PIN错误")
+}
+
+// StaffSetPin 设置平板登录PIN, 4~6位数字
+func StaffSetPin(m *Staff, pin string) error {
+	if len(pin) < 4 || len(pin) > 6 || utils.GetPureNumber(pin) != pin {
+		return errors.New("PIN须为4~6位数字")
+	}
+	hashed, err := utils.HashPassword(pin)
+	if err != nil {
+		return err
+	}
+	m.Pin = hashed
+	_, err = orm.NewOrm().Update(m, "Pin")
+	return err
+}
@@ -0,0 +1,303 @@
+package models
+
+import (
+	"BossBar/enums"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// Drink 商户的酒水单
+type Drink struct {
+	Id         int       `json:"id"`
+	MerchantId int       `orm:"index" json:"-"`
+	Category   string    `orm:"size(32)" json:"category"` //分类, 如洋酒、香槟、啤酒
+	Name       string    `orm:"size(64)" json:"name"`
+	BottleSize string    `orm:"size(16)" json:"bottle_size"` //规格, 如750ml
+	Price      float64   `orm:"digits(12);decimals(2)" json:"price"`
+	Available  bool      `json:"available"` //是否有货
+	Sort       int       `json:"sort"`
+	Status     int       `orm:"default(1)" json:"-"`
+	CreateTime time.Time `orm:"auto_now_add;type(datetime)" json:"-"`
+	UpdateTime time.Time `orm:"auto_now;type(datetime)" json:"-"`
+}
+
+func (a *Drink) TableName() string {
+	return DrinkTBName()
+}
+
+// PreOrder 订台预订的酒水, 计入最低消费
+type PreOrder struct {
+	Id          int       `json:"id"`
+	MerchantId  int       `orm:"index" json:"-"`
+	BizDate     string    `orm:"size(10);index" json:"biz_date"`
+	DeskId      int       `orm:"index" json:"-"`
+	GroupId     int       `json:"-"`
+	SiteName    string    `orm:"size(255)" json:"site_name"`
+	DrinkId     int       `json:"drink_id"`
+	DrinkName   string    `orm:"size(64)" json:"drink_name"` //下单时的酒水名称和价格, 改价不影响已下的单
+	BottleSize  string    `orm:"size(16)" json:"bottle_size"`
+	Price       float64   `orm:"digits(12);decimals(2)" json:"price"`
+	Quantity    int       `json:"quantity"`
+	Amount      float64   `orm:"digits(12);decimals(2)" json:"amount"`
+	Status      int       `json:"status"`       //enums.PreOrder*
+	PromotionId int       `json:"promotion_id"` //活动赠送的酒水
+	StaffId     int       `json:"staff_id"`
+	CreateTime  time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+}
+
+func (a *PreOrder) TableName() string {
+	return PreOrderTBName()
+}
+
+// DrinkSummary 营业日的酒水预订汇总
+type DrinkSummary struct {
+	DrinkName  string  `json:"drink_name"`
+	BottleSize string  `json:"bottle_size"`
+	Quantity   int     `json:"quantity"`
+	Amount     float64 `json:"amount"`
+}
+
+// NightlyReport 营业日报表
+type NightlyReport struct {
+	BizDate  string             `json:"biz_date"`
+	Bookings []*DepositView     `json:"bookings"`
+	Drinks   []*DrinkSummary    `json:"drinks"`
+	Totals   map[string]float64 `json:"totals"`
+}
+
+// DrinkList 酒水单, 按分类和排序, onlyAvailable时只返回有货的
+func DrinkList(merchantId int, onlyAvailable bool) []*Drink {
+	var list []*Drink
+	qs := orm.NewOrm().QueryTable(DrinkTBName()).Filter("merchant_id", merchantId).Filter("status", enums.Enabled)
+	if onlyAvailable {
+		qs = qs.Filter("available", true)
+	}
+	qs.OrderBy("category", "sort", "id").All(&list)
+	return list
+}
+
+// DrinkSave 新增或修改酒水
+func DrinkSave(merchantId int, m *Drink) error {
+	m.Category = strings.TrimSpace(m.Category)
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" || m.Category == "" {
+		return errors.New("请填写酒水名称和分类")
+	}
+	if m.Price < 0 {
+		return errors.New("价格不正确")
+	}
+	o := orm.NewOrm()
+	if m.Id == 0 {
+		m.MerchantId = merchantId
+		m.Status = enums.Enabled
+		_, err := o.Insert(m)
+		return err
+	}
+	cur := Drink{Id: m.Id}
+	if err := o.Read(&cur); err != nil || cur.MerchantId != merchantId || cur.Status != enums.Enabled {
+		return errors.New("酒水不存在")
+	}
+	m.MerchantId = merchantId
+	m.Status = enums.Enabled
+	_, err := o.Update(m, "Category", "Name", "BottleSize", "Price", "Available", "Sort", "UpdateTime")
+	return err
+}
+
+// DrinkDelete 删除酒水, 已下的预订不受影响
+func DrinkDelete(merchantId, id int) error {
+	num, err := orm.NewOrm().QueryTable(DrinkTBName()).Filter("merchant_id", merchantId).Filter("id", id).
+		Filter("status", enums.Enabled).Update(orm.Params{"status": enums.Deleted})
+	if err == nil && num == 0 {
+		return errors.New("酒水不存在")
+	}
+	return err
+}
+
+// 一单订台的标识, 拼台按组, 单台按订台id
+func bookingKey(desk *BarDesk) string {
+	if desk.GroupId > 0 {
+		return fmt.Sprintf("g%d", desk.GroupId)
+	}
+	return fmt.Sprintf("d%d", desk.Id)
+}
+
+func preOrderKey(m *PreOrder) string {
+	if m.GroupId > 0 {
+		return fmt.Sprintf("g%d", m.GroupId)
+	}
+	return fmt.Sprintf("d%d", m.DeskId)
+}
+
+// 一单订台的预订酒水查询条件
+func bookingPreOrders(o orm.Ormer, merchantId int, desks []*BarDesk) orm.QuerySeter {
+	qs := o.QueryTable(PreOrderTBName()).Filter("merchant_id", merchantId).Filter("status", enums.PreOrderActive)
+	if desks[0].GroupId > 0 {
+		return qs.Filter("group_id", desks[0].GroupId)
+	}
+	return qs.Filter("desk_id__in", barDeskIds(desks))
+}
+
+// 一单订台的预订酒水金额
+func preOrderAmount(merchantId int, desks []*BarDesk) float64 {
+	var list []*PreOrder
+	bookingPreOrders(orm.NewOrm(), merchantId, desks).All(&list)
+	amount := 0.0
+	for _, v := range list {
+		amount += v.Amount
+	}
+	return amount
+}
+
+// 营业日或月份内每单的预订酒水金额 bookingKey => amount
+func preOrderTotals(merchantId int, period string) map[string]float64 {
+	var list []*PreOrder
+	qs := orm.NewOrm().QueryTable(PreOrderTBName()).Filter("merchant_id", merchantId).Filter("status", enums.PreOrderActive)
+	if len(period) == 7 {
+		qs = qs.Filter("biz_date__startswith", period)
+	} else {
+		qs = qs.Filter("biz_date", period)
+	}
+	qs.All(&list)
+	totals := make(map[string]float64)
+	for _, v := range list {
+		totals[preOrderKey(v)] += v.Amount
+	}
+	return totals
+}
+
+// 转台后预订酒水跟随到新的订台
+func movePreOrders(o orm.Ormer, merchantId int, desks []*BarDesk, to []string, deskId, groupId int) error {
+	_, err := bookingPreOrders(o, merchantId, desks).Update(orm.Params{
+		"desk_id":   deskId,
+		"group_id":  groupId,
+		"site_name": strings.Join(to, ","),
+	})
+	return err
+}
+
+// PreOrderList 一单订台的预订酒水
+func PreOrderList(merchantId int, desks []*BarDesk) []*PreOrder {
+	var list []*PreOrder
+	if len(desks) == 0 {
+		return list
+	}
+	bookingPreOrders(orm.NewOrm(), merchantId, desks).OrderBy("id").All(&list)
+	return list
+}
+
+// PreOrderAdd 为订台预订酒水, 拼台时记在整组上
+func PreOrderAdd(merchantId int, desks []*BarDesk, drinkId, quantity, staffId int) (*PreOrder, error) {
+	if len(desks) == 0 {
+		return nil, errors.New("订台信息不存在")
+	}
+	if len(splitBookings(desks)) > 1 {
+		return nil, errors.New("一次只能为一单订台预订酒水")
+	}
+	if quantity <= 0 || quantity > 99 {
+		return nil, errors.New("数量不正确")
+	}
+	desk := desks[0]
+	if desk.State != enums.DeskStatePending && desk.State != enums.DeskStateConfirmed && desk.State != enums.DeskStateSeated {
+		return nil, errors.New("订台已结束, 不能预订酒水")
+	}
+	o := orm.NewOrm()
+	drink := Drink{Id: drinkId}
+	if err := o.Read(&drink); err != nil || drink.MerchantId != merchantId || drink.Status != enums.Enabled {
+		return nil, errors.New("酒水不存在")
+	}
+	if !drink.Available {
+		return nil, errors.New(drink.Name + "暂时缺货")
+	}
+	sites := make([]string, 0, len(desks))
+	for _, v := range desks {
+		sites = append(sites, v.SiteName)
+	}
+	m := &PreOrder{
+		MerchantId: merchantId,
+		BizDate:    desk.BizDate,
+		DeskId:     desk.Id,
+		GroupId:    desk.GroupId,
+		SiteName:   strings.Join(sites, ","),
+		DrinkId:    drink.Id,
+		DrinkName:  drink.Name,
+		BottleSize: drink.BottleSize,
+		Price:      drink.Price,
+		Quantity:   quantity,
+		Amount:     drink.Price * float64(quantity),
+		Status:     enums.PreOrderActive,
+		StaffId:    staffId,
+	}
+	if _, err := o.Insert(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PreOrderCancel 取消预订的酒水
+func PreOrderCancel(merchantId, id int) (*PreOrder, error) {
+	m := PreOrder{Id: id}
+	o := orm.NewOrm()
+	if err := o.Read(&m); err != nil || m.MerchantId != merchantId || m.Status != enums.PreOrderActive {
+		return nil, errors.New("预订不存在")
+	}
+	// 订台结束后预订金额已计入客户消费和业绩, 不能再取消
+	qs := liveBarDesks(o, merchantId)
+	if m.GroupId > 0 {
+		qs = qs.Filter("group_id", m.GroupId)
+	} else {
+		qs = qs.Filter("id", m.DeskId)
+	}
+	if !qs.Exist() {
+		return nil, errors.New("订台已结束, 不能取消预订的酒水")
+	}
+	if m.PromotionId > 0 {
+		return nil, errors.New("活动赠送的酒水须取消特惠台标记")
+	}
+	m.Status = enums.PreOrderCancelled
+	if _, err := o.Update(&m, "Status"); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// NightlyReportOf 营业日报表: 每单的最低消费、预订酒水和现场消费, 以及酒水汇总
+func NightlyReportOf(merchantId int, bizDate string) *NightlyReport {
+	report := &NightlyReport{BizDate: bizDate, Bookings: make([]*DepositView, 0), Totals: make(map[string]float64)}
+	for _, v := range bookingViews(merchantId, bizDate) {
+		if v.State == enums.DeskStateCancelled {
+			continue
+		}
+		report.Bookings = append(report.Bookings, v)
+		report.Totals["min_spend"] += v.MinSpend
+		report.Totals["pre_order"] += v.PreOrder
+		report.Totals["spend"] += v.Spend
+		report.Totals["shortfall"] += v.Shortfall
+		report.Totals["discount"] += v.Discount
+	}
+
+	var list []*PreOrder
+	orm.NewOrm().QueryTable(PreOrderTBName()).Filter("merchant_id", merchantId).Filter("biz_date", bizDate).
+		Filter("status", enums.PreOrderActive).All(&list)
+	drinks := make(map[string]*DrinkSummary)
+	for _, v := range list {
+		key := v.DrinkName + "|" + v.BottleSize
+		if _, ok := drinks[key]; !ok {
+			drinks[key] = &DrinkSummary{DrinkName: v.DrinkName, BottleSize: v.BottleSize}
+		}
+		drinks[key].Quantity += v.Quantity
+		drinks[key].Amount += v.Amount
+	}
+	report.Drinks = make([]*DrinkSummary, 0, len(drinks))
+	for _, v := range drinks {
+		report.Drinks = append(report.Drinks, v)
+	}
+	sort.Slice(report.Drinks, func(i, j int) bool {
+		return report.Drinks[i].Amount > report.Drinks[j].Amount
+	})
+	return report
+}
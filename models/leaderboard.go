@@ -0,0 +1,226 @@
+package models
+
+import (
+	"BossBar/enums"
+	"BossBar/utils"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// 排行榜指标
+const (
+	RankBookings = "bookings" //订台数, 取消的不计
+	RankSeated   = "seated"   //到店人数
+	RankSpend    = "spend"    //消费金额, 缓存中按分存储
+)
+
+var RankNames = map[string]string{
+	RankBookings: "订台数",
+	RankSeated:   "到店人数",
+	RankSpend:    "消费金额",
+}
+
+// 排行榜周期
+const (
+	RankNightly = "night" //营业日
+	RankMonthly = "month" //自然月
+)
+
+// RankEntry 排行榜的一行
+type RankEntry struct {
+	Rank      int     `json:"rank"`
+	StaffId   int     `json:"staff_id"`
+	StaffName string  `json:"staff_name"`
+	Score     float64 `json:"score"`
+}
+
+// 排行榜缓存key, period为营业日2006-01-02或月份2006-01
+func rankKey(merchantId int, metric, period string) string {
+	return fmt.Sprintf("rank:%d:%s:%s", merchantId, metric, period)
+}
+
+// 营业日所在的日榜和月榜
+func rankPeriods(bizDate string) []string {
+	if len(bizDate) < 7 {
+		return nil
+	}
+	return []string{bizDate, bizDate[:7]}
+}
+
+// 榜单的版本号, 每次增量更新或清除时加一, 重建时据此发现期间的变动
+func rankVerKey(key string) string {
+	return key + ":ver"
+}
+
+func rankTouch(key, period string) {
+	utils.IncrByCache(rankVerKey(key), 1)
+	utils.ExpireCache(rankVerKey(key), rankTimeout(period))
+}
+
+// 缓存保留时长(秒), 过期后读取时从数据库重建
+func rankTimeout(period string) int64 {
+	if len(period) == 7 {
+		return 400 * 86400
+	}
+	return 40 * 86400
+}
+
+// 给员工的日榜和月榜加分
+func rankIncr(merchantId, staffId int, bizDate, metric string, increment int64) {
+	if staffId == 0 || increment == 0 {
+		return
+	}
+	for _, period := range rankPeriods(bizDate) {
+		key := rankKey(merchantId, metric, period)
+		rankTouch(key, period)
+		// 未建立的榜单不增量更新, 读取时整体重建
+		if exists, _ := utils.ExistsCache(key); !exists {
+			continue
+		}
+		utils.ZIncrByCache(key, strconv.Itoa(staffId), increment)
+		utils.ExpireCache(key, rankTimeout(period))
+	}
+}
+
+// 订台归属变更后清掉相关榜单, 下次读取时重建
+func rankReset(merchantId int, bizDate string) {
+	for metric := range RankNames {
+		for _, period := range rankPeriods(bizDate) {
+			key := rankKey(merchantId, metric, period)
+			rankTouch(key, period)
+			utils.DelCache(key)
+		}
+	}
+}
+
+// 订台状态流转对排行榜的影响
+func rankTransit(merchantId int, desks []*BarDesk, to int) {
+	first := desks[0]
+	switch to {
+	case enums.DeskStateCancelled:
+		rankIncr(merchantId, first.ReserveStaffId, first.BizDate, RankBookings, -1)
+	case enums.DeskStateSeated:
+		rankIncr(merchantId, first.ReserveStaffId, first.BizDate, RankSeated, int64(partySize(desks)))
+	case enums.DeskStateCompleted:
+		rankIncr(merchantId, first.ReserveStaffId, first.BizDate, RankSpend, spendCents(desks, preOrderAmount(merchantId, desks)))
+	}
+}
+
+// 一单的到店人数, 未填人数按一人计
+func partySize(desks []*BarDesk) int {
+	if desks[0].PartySize > 0 {
+		return desks[0].PartySize
+	}
+	return 1
+}
+
+// 一单的消费金额(分), 含预订酒水
+func spendCents(desks []*BarDesk, preOrder float64) int64 {
+	spend := preOrder
+	for _, v := range desks {
+		spend += v.Spend
+	}
+	return int64(math.Round(spend * 100))
+}
+
+// 从订台数据统计某周期各员工的业绩 metric => staff_id => score
+func rankAggregate(merchantId int, period string, staffIds []int) map[string]map[int]float64 {
+	var list []*BarDesk
+	qs := orm.NewOrm().QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).
+		Filter("reserve_staff_id__gt", 0).Exclude("state", enums.DeskStateCancelled)
+	if len(period) == 7 {
+		qs = qs.Filter("biz_date__startswith", period)
+	} else {
+		qs = qs.Filter("biz_date", period)
+	}
+	if len(staffIds) > 0 {
+		qs = qs.Filter("reserve_staff_id__in", staffIds)
+	}
+	qs.OrderBy("id").All(&list)
+
+	preOrders := preOrderTotals(merchantId, period)
+	scores := make(map[string]map[int]float64, len(RankNames))
+	for metric := range RankNames {
+		scores[metric] = make(map[int]float64)
+	}
+	for _, desks := range splitBookings(list) {
+		first := desks[0]
+		scores[RankBookings][first.ReserveStaffId]++
+		if first.State == enums.DeskStateSeated || first.State == enums.DeskStateCompleted {
+			scores[RankSeated][first.ReserveStaffId] += float64(partySize(desks))
+		}
+		if first.State == enums.DeskStateCompleted {
+			scores[RankSpend][first.ReserveStaffId] += float64(spendCents(desks, preOrders[bookingKey(first)]))
+		}
+	}
+	return scores
+}
+
+// RankRebuild 从订台数据重建某周期的排行榜
+// 先写入临时key再RENAME替换, 重建期间有增量更新或清除时重新统计, 避免丢失期间的变动
+func RankRebuild(merchantId int, metric, period string) error {
+	if _, ok := RankNames[metric]; !ok {
+		return errors.New("排行榜不存在")
+	}
+	key := rankKey(merchantId, metric, period)
+	for retry := 0; retry < 3; retry++ {
+		ver, _ := utils.GetPureCache(rankVerKey(key))
+		// 空榜也要占位, 避免每次读取都重建
+		pairs := map[string]float64{"0": 0}
+		for staffId, score := range rankAggregate(merchantId, period, nil)[metric] {
+			pairs[strconv.Itoa(staffId)] = score
+		}
+		tmp := key + ":rebuild:" + utils.SecureRandomString(8)
+		if err := utils.ZAddCache(tmp, pairs); err != nil {
+			return err
+		}
+		utils.ExpireCache(tmp, rankTimeout(period))
+		if err := utils.RenameCache(tmp, key); err != nil {
+			utils.DelCache(tmp)
+			return err
+		}
+		if cur, _ := utils.GetPureCache(rankVerKey(key)); cur == ver {
+			return nil
+		}
+	}
+	// 一直有变动时清掉榜单, 下次读取时再重建
+	return utils.DelCache(key)
+}
+
+// RankList 排行榜, 缓存不存在时先重建
+func RankList(merchantId int, metric, period string, limit int) ([]*RankEntry, error) {
+	key := rankKey(merchantId, metric, period)
+	if exists, _ := utils.ExistsCache(key); !exists {
+		if err := RankRebuild(merchantId, metric, period); err != nil {
+			return nil, err
+		}
+	}
+	pairs, err := utils.ZRevRangeCache(key, 0, limit, true)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int]string)
+	for _, v := range StaffList(merchantId) {
+		names[v.Id] = v.RealName
+	}
+	list := make([]*RankEntry, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		staffId, _ := strconv.Atoi(pairs[i])
+		if staffId == 0 {
+			continue
+		}
+		score, _ := strconv.ParseFloat(pairs[i+1], 64)
+		if metric == RankSpend {
+			score /= 100
+		}
+		list = append(list, &RankEntry{Rank: len(list) + 1, StaffId: staffId, StaffName: names[staffId], Score: score})
+		if len(list) == limit {
+			break
+		}
+	}
+	return list, nil
+}
@@ -0,0 +1,181 @@
+package models
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/utils"
+	"errors"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// Merchant 商户(酒吧)
+type Merchant struct {
+	Id         int
+	Name       string    `orm:"size(64)"`
+	Phone      string    `orm:"size(32);unique"`
+	Status     int       `orm:"default(1)"`
+	CreateTime time.Time `orm:"auto_now_add;type(datetime)"`
+}
+
+func (a *Merchant) TableName() string {
+	return MerchantTBName()
+}
+
+// MerchantOne 根据id获取单条
+func MerchantOne(id int) (*Merchant, error) {
+	m := Merchant{Id: id}
+	err := orm.NewOrm().Read(&m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// MerchantSetting 商户设置
+type MerchantSetting struct {
+	Id              int
+	MerchantId      int       `orm:"unique"`
+	OpenTime        string    `orm:"size(8)"` //营业开始 HH:MM
+	CloseTime       string    `orm:"size(8)"` //营业结束 HH:MM, 小于开始时间表示次日
+	DefaultLayout   int       //默认台型
+	ReserveDuration int       //订台默认时长(分钟)
+	NoShowGrace     int       //超过到店时间多少分钟未到视为爽约, 0为不自动释放
+	RefundHours     int       //到店前多少小时取消可退定金
+	UpdateTime      time.Time `orm:"auto_now;type(datetime)"`
+}
+
+func (a *MerchantSetting) TableName() string {
+	return MerchantSettingTBName()
+}
+
+// MerchantSettingOne 获取商户设置, 不存在时返回默认值
+func MerchantSettingOne(merchantId int) *MerchantSetting {
+	m := MerchantSetting{}
+	if err := orm.NewOrm().QueryTable(MerchantSettingTBName()).Filter("merchant_id", merchantId).One(&m); err != nil {
+		return defaultMerchantSetting(merchantId)
+	}
+	return &m
+}
+
+// MerchantSettingSave 保存商户设置的指定字段, 商户还没有设置时新建
+func MerchantSettingSave(m *MerchantSetting, cols ...string) error {
+	if m.NoShowGrace < 0 || m.NoShowGrace > 720 {
+		return errors.New("爽约宽限须在0~720分钟之间, 0为不自动释放")
+	}
+	if m.RefundHours < 0 || m.RefundHours > 720 {
+		return errors.New("退定金时限须在0~720小时之间, 0为默认2小时")
+	}
+	o := orm.NewOrm()
+	if m.Id == 0 {
+		_, err := o.Insert(m)
+		return err
+	}
+	_, err := o.Update(m, append(cols, "UpdateTime")...)
+	return err
+}
+
+func defaultMerchantSetting(merchantId int) *MerchantSetting {
+	return &MerchantSetting{
+		MerchantId:      merchantId,
+		OpenTime:        "20:00",
+		CloseTime:       "06:00",
+		ReserveDuration: 180,
+		NoShowGrace:     30,
+		RefundHours:     2,
+	}
+}
+
+// 营业到次日
+func (a *MerchantSetting) overnight() bool {
+	return a.CloseTime < a.OpenTime
+}
+
+// BizDate t所属的营业日, 跨夜营业时收市前算前一天
+func (a *MerchantSetting) BizDate(t time.Time) string {
+	if a.overnight() && t.Format(conf.ClockLayout) < a.CloseTime {
+		t = t.AddDate(0, 0, -1)
+	}
+	return t.Format(conf.BizDateLayout)
+}
+
+// ArriveAt 营业日bizDate的clock时刻, 跨夜营业时收市前的时刻在次日, 与BizDate的划分一致
+func (a *MerchantSetting) ArriveAt(bizDate, clock string) (time.Time, error) {
+	t, err := time.ParseInLocation(conf.BizDateLayout+" "+conf.ClockLayout, bizDate+" "+clock, time.Local)
+	if err != nil {
+		return t, errors.New("到店时间格式错误")
+	}
+	if a.overnight() && clock < a.CloseTime {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// Grace 爽约宽限时长, 为0表示不自动释放
+func (a *MerchantSetting) Grace() time.Duration {
+	if a.NoShowGrace <= 0 {
+		return 0
+	}
+	return time.Duration(a.NoShowGrace) * time.Minute
+}
+
+// RefundBefore 到店前多久取消可退定金
+func (a *MerchantSetting) RefundBefore() time.Duration {
+	if a.RefundHours <= 0 {
+		return 2 * time.Hour
+	}
+	return time.Duration(a.RefundHours) * time.Hour
+}
+
+// Duration 订台默认时长
+func (a *MerchantSetting) Duration() int {
+	if a.ReserveDuration <= 0 {
+		return 180
+	}
+	return a.ReserveDuration
+}
+
+// MerchantRegister 商户入驻: 创建商户、老板账号、默认平面图和默认设置
+func MerchantRegister(m *Merchant, owner *Staff, password string) error {
+	sites, err := DefaultLayoutSites()
+	if err != nil {
+		return err
+	}
+	o := orm.NewOrm()
+	if o.QueryTable(MerchantTBName()).Filter("phone", m.Phone).Exist() {
+		return errors.New("该手机号已入驻")
+	}
+	if o.QueryTable(StaffTBName()).Filter("user_name", owner.UserName).Exist() {
+		return errors.New("用户名已存在")
+	}
+	if owner.Password, err = utils.HashPassword(password); err != nil {
+		return err
+	}
+
+	o.Begin()
+	m.Status = enums.Enabled
+	if _, err = o.Insert(m); err != nil {
+		o.Rollback()
+		return err
+	}
+	owner.Merchant = m
+	owner.Role = enums.RoleOwner
+	owner.Status = enums.Enabled
+	if _, err = o.Insert(owner); err != nil {
+		o.Rollback()
+		return err
+	}
+	if _, err = o.Insert(defaultMerchantSetting(m.Id)); err != nil {
+		o.Rollback()
+		return err
+	}
+	for _, site := range sites {
+		site.MerchantId = m.Id
+	}
+	if _, err = o.InsertMulti(len(sites), sites); err != nil {
+		o.Rollback()
+		return err
+	}
+	return o.Commit()
+}
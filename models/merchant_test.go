@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+// 跨夜营业时收市前的时刻属于前一个营业日
+func TestMerchantSettingBizDate(t *testing.T) {
+	overnight := &MerchantSetting{OpenTime: "20:00", CloseTime: "06:00"}
+	daytime := &MerchantSetting{OpenTime: "10:00", CloseTime: "22:00"}
+	cases := []struct {
+		setting *MerchantSetting
+		t       time.Time
+		want    string
+	}{
+		{overnight, time.Date(2026, 5, 1, 21, 0, 0, 0, time.Local), "2026-05-01"},
+		{overnight, time.Date(2026, 5, 2, 2, 30, 0, 0, time.Local), "2026-05-01"},
+		{overnight, time.Date(2026, 5, 2, 6, 0, 0, 0, time.Local), "2026-05-02"},
+		{overnight, time.Date(2026, 5, 1, 0, 0, 0, 0, time.Local), "2026-04-30"},
+		{daytime, time.Date(2026, 5, 1, 2, 30, 0, 0, time.Local), "2026-05-01"},
+	}
+	for _, c := range cases {
+		if got := c.setting.BizDate(c.t); got != c.want {
+			t.Errorf("%s-%s营业, %s的营业日为%s, 期望%s", c.setting.OpenTime, c.setting.CloseTime, c.t.Format("2006-01-02 15:04"), got, c.want)
+		}
+	}
+}
+
+// 跨夜营业时营业日收市前的到店时间在次日, 且仍属于该营业日
+func TestMerchantSettingArriveAt(t *testing.T) {
+	overnight := &MerchantSetting{OpenTime: "20:00", CloseTime: "06:00"}
+	daytime := &MerchantSetting{OpenTime: "10:00", CloseTime: "22:00"}
+	cases := []struct {
+		setting *MerchantSetting
+		clock   string
+		want    time.Time
+	}{
+		{overnight, "21:30", time.Date(2026, 5, 1, 21, 30, 0, 0, time.Local)},
+		{overnight, "01:00", time.Date(2026, 5, 2, 1, 0, 0, 0, time.Local)},
+		{overnight, "05:59", time.Date(2026, 5, 2, 5, 59, 0, 0, time.Local)},
+		{overnight, "06:00", time.Date(2026, 5, 1, 6, 0, 0, 0, time.Local)},
+		{daytime, "01:00", time.Date(2026, 5, 1, 1, 0, 0, 0, time.Local)},
+	}
+	for _, c := range cases {
+		got, err := c.setting.ArriveAt("2026-05-01", c.clock)
+		if err != nil {
+			t.Errorf("ArriveAt(%s): %v", c.clock, err)
+			continue
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("%s-%s营业, 到店%s为%s, 期望%s", c.setting.OpenTime, c.setting.CloseTime, c.clock, got, c.want)
+		}
+		if bizDate := c.setting.BizDate(got); bizDate != "2026-05-01" {
+			t.Errorf("到店%s的营业日为%s, 期望2026-05-01", c.clock, bizDate)
+		}
+	}
+	for _, v := range []string{"", "25:00", "9点"} {
+		if _, err := overnight.ArriveAt("2026-05-01", v); err == nil {
+			t.Errorf("ArriveAt(%q) 应返回错误", v)
+		}
+	}
+}
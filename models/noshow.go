@@ -0,0 +1,64 @@
+package models
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	log "github.com/sirupsen/logrus"
+)
+
+// InitNoShowJob 定时释放超过到店时间+宽限仍未到店的订台
+func InitNoShowJob() {
+	interval := beego.AppConfig.DefaultInt("noshow::interval", 60)
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(time.Duration(interval) * time.Second) {
+			ReleaseNoShows(time.Now())
+		}
+	}()
+}
+
+// ReleaseNoShows 标记爽约并释放台位, 记录日志并通知前台
+func ReleaseNoShows(now time.Time) {
+	var list []*BarDesk
+	o := orm.NewOrm()
+	// 只看最近两天, 更早的订台视为历史数据
+	o.QueryTable(BarDeskTBName()).Filter("state__in", enums.DeskStatePending, enums.DeskStateConfirmed).
+		Filter("arrive_time__lt", now).Filter("arrive_time__gt", now.Add(-48*time.Hour)).OrderBy("id").All(&list)
+
+	settings := make(map[int]*MerchantSetting)
+	for _, desks := range splitBookings(list) {
+		v := desks[0]
+		setting, ok := settings[v.MerchantId]
+		if !ok {
+			setting = MerchantSettingOne(v.MerchantId)
+			settings[v.MerchantId] = setting
+		}
+		grace := setting.Grace()
+		if grace <= 0 || v.ArriveTime.Add(grace).After(now) {
+			continue
+		}
+		// 多实例同时运行时只有一个能更新成功
+		err := transitBarDesks(o, v.MerchantId, desks, enums.DeskStateNoShow, 0, now)
+		if err == errDeskStateChanged {
+			continue
+		}
+		if err != nil {
+			log.Errorf("[noshow] release desk %d failed, err:%s", v.Id, err.Error())
+			continue
+		}
+		logBarDeskTransit(v.MerchantId, desks, enums.DeskStateNoShow, 0, "系统", nil)
+		sites := make([]string, 0, len(desks))
+		for _, d := range desks {
+			sites = append(sites, d.SiteName)
+		}
+		PushAlarm(v.MerchantId, fmt.Sprintf("%s %s %s %s 超时未到店, 已自动释放", v.BizDate, strings.Join(sites, ","), v.ArriveTime.Format(conf.ClockLayout), v.CustomerName))
+	}
+}
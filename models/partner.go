@@ -0,0 +1,126 @@
+package models
+
+import (
+	"BossBar/enums"
+	"BossBar/utils"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// Partner 接入订台接口的合作方, 如订台平台或商户自己的脚本
+type Partner struct {
+	Id         int       `json:"id"`
+	MerchantId int       `json:"-"`
+	Name       string    `orm:"size(32)" json:"name"`
+	AppKey     string    `orm:"size(32);unique" json:"app_key"`
+	AppSecret  string    `orm:"size(64)" json:"-"` //签名密钥, 只在创建时返回一次
+	Status     int       `orm:"default(1)" json:"status"`
+	CreateTime time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+}
+
+func (a *Partner) TableName() string {
+	return PartnerTBName()
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// PartnerSignRequired 合作方请求必须携带且不能为空的参数
+var PartnerSignRequired = []string{"app_key", "timestamp", "nonce", "sign"}
+
+// PartnerSignParams 校验请求参数并返回参与签名的参数(除sign外的全部参数)
+// 每个参数只能出现一次, 只能是PartnerSignRequired或allowed中的参数
+func PartnerSignParams(form url.Values, allowed []string) (url.Values, error) {
+	known := make(map[string]bool, len(PartnerSignRequired)+len(allowed))
+	for _, k := range PartnerSignRequired {
+		known[k] = true
+	}
+	for _, k := range allowed {
+		known[k] = true
+	}
+	params := make(url.Values, len(form))
+	for k, v := range form {
+		if !known[k] {
+			return nil, errors.New("参数" + k + "无效")
+		}
+		if len(v) != 1 {
+			return nil, errors.New("参数" + k + "重复")
+		}
+		if k != "sign" {
+			params[k] = v
+		}
+	}
+	for _, k := range PartnerSignRequired {
+		if form.Get(k) == "" {
+			return nil, errors.New("缺少参数" + k)
+		}
+	}
+	return params, nil
+}
+
+// PartnerSign 签名串为按key排序并url编码的k1=v1&k2=v2..., 空值参数也参与签名
+func PartnerSign(params url.Values, secret string) string {
+	return utils.GenerateSign(params.Encode(), secret, sha256.New)
+}
+
+// VerifySign 校验合作方签名
+func (a *Partner) VerifySign(params url.Values, sign string) bool {
+	return subtle.ConstantTimeCompare([]byte(PartnerSign(params, a.AppSecret)), []byte(sign)) == 1
+}
+
+// PartnerCreate 为商户创建合作方并生成app_key和app_secret
+func PartnerCreate(merchantId int, name string) (*Partner, error) {
+	m := &Partner{MerchantId: merchantId, Name: name, Status: enums.Enabled}
+	var err error
+	if m.AppKey, err = randomHex(12); err != nil {
+		return nil, err
+	}
+	if m.AppSecret, err = randomHex(24); err != nil {
+		return nil, err
+	}
+	if _, err = orm.NewOrm().Insert(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// PartnerOneByKey 根据app_key获取已启用的合作方
+func PartnerOneByKey(appKey string) (*Partner, error) {
+	m := Partner{}
+	err := orm.NewOrm().QueryTable(PartnerTBName()).Filter("app_key", appKey).Filter("status", enums.Enabled).One(&m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// PartnerList 商户下的合作方
+func PartnerList(merchantId int) []*Partner {
+	var list []*Partner
+	orm.NewOrm().QueryTable(PartnerTBName()).Filter("merchant_id", merchantId).Exclude("status", enums.Deleted).OrderBy("-id").All(&list)
+	return list
+}
+
+// PartnerSetStatus 启用或停用合作方
+func PartnerSetStatus(merchantId, id, status int) error {
+	m := Partner{Id: id}
+	o := orm.NewOrm()
+	if err := o.Read(&m); err != nil || m.MerchantId != merchantId || m.Status == enums.Deleted {
+		return errors.New("合作方不存在")
+	}
+	m.Status = status
+	_, err := o.Update(&m, "Status")
+	return err
+}
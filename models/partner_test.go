@@ -0,0 +1,62 @@
+package models
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPartnerSignParams(t *testing.T) {
+	allowed := []string{"biz_date", "site_name"}
+	base := "app_key=k&timestamp=1&nonce=n&sign=s"
+	cases := []struct {
+		query string
+		ok    bool
+	}{
+		{base, true},
+		{base + "&biz_date=2026-05-01&site_name=", true},
+		{"app_key=k&timestamp=1&nonce=n", false},
+		{"app_key=k&timestamp=1&nonce=&sign=s", false},
+		{base + "&biz_date=2026-05-01&biz_date=2026-05-02", false},
+		{base + "&sign=t", false},
+		{base + "&merchant_id=1", false},
+	}
+	for _, c := range cases {
+		form, _ := url.ParseQuery(c.query)
+		params, err := PartnerSignParams(form, allowed)
+		if (err == nil) != c.ok {
+			t.Errorf("PartnerSignParams(%s) err = %v", c.query, err)
+			continue
+		}
+		if err == nil && params.Get("sign") != "" {
+			t.Errorf("PartnerSignParams(%s) 参与签名的参数不应包含sign", c.query)
+		}
+	}
+}
+
+// 签名覆盖全部参数, 改动或增删任一参数都会使签名失效
+func TestPartnerVerifySign(t *testing.T) {
+	partner := &Partner{AppSecret: "secret"}
+	params := url.Values{"app_key": {"k"}, "timestamp": {"1"}, "nonce": {"n"}, "site_name": {"A1"}, "remark": {""}}
+	sign := PartnerSign(params, partner.AppSecret)
+	if !partner.VerifySign(params, sign) {
+		t.Fatal("签名校验失败")
+	}
+	// 参数顺序不影响签名
+	reordered, _ := url.ParseQuery("site_name=A1&remark=&nonce=n&timestamp=1&app_key=k")
+	if !partner.VerifySign(reordered, sign) {
+		t.Error("参数顺序不同时签名校验失败")
+	}
+	tampered := []url.Values{
+		{"app_key": {"k"}, "timestamp": {"1"}, "nonce": {"n"}, "site_name": {"A2"}, "remark": {""}},
+		{"app_key": {"k"}, "timestamp": {"1"}, "nonce": {"n"}, "site_name": {"A1"}},
+		{"app_key": {"k"}, "timestamp": {"1"}, "nonce": {"n"}, "site_name": {"A1"}, "remark": {""}, "party_size": {"9"}},
+	}
+	for _, v := range tampered {
+		if partner.VerifySign(v, sign) {
+			t.Errorf("参数被改动时签名仍有效: %s", v.Encode())
+		}
+	}
+	if (&Partner{AppSecret: "other"}).VerifySign(params, sign) {
+		t.Error("密钥不同时签名仍有效")
+	}
+}
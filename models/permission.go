@@ -0,0 +1,103 @@
+package models
+
+import (
+	"BossBar/enums"
+	"errors"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// RolePermission 商户配置的角色权限, 一行表示某角色拥有某项权限
+type RolePermission struct {
+	Id         int
+	MerchantId int
+	Role       int
+	Permission string `orm:"size(32)"`
+}
+
+func (a *RolePermission) TableName() string {
+	return RolePermissionTBName()
+}
+
+func (a *RolePermission) TableUnique() [][]string {
+	return [][]string{
+		{"MerchantId", "Role", "Permission"},
+	}
+}
+
+// RolePermissionMatrix 商户的角色权限表 role => permissions, 未配置时返回默认值
+func RolePermissionMatrix(merchantId int) map[int][]string {
+	var list []*RolePermission
+	orm.NewOrm().QueryTable(RolePermissionTBName()).Filter("merchant_id", merchantId).All(&list)
+	matrix := make(map[int][]string, len(enums.RoleNames))
+	if len(list) == 0 {
+		for role, perms := range enums.DefaultRolePermissions {
+			matrix[role] = perms
+		}
+		return matrix
+	}
+	for role := range enums.RoleNames {
+		matrix[role] = []string{}
+	}
+	for _, v := range list {
+		matrix[v.Role] = append(matrix[v.Role], v.Permission)
+	}
+	matrix[enums.RoleOwner] = enums.Permissions
+	return matrix
+}
+
+// StaffHasPermission 员工是否拥有某项权限, 老板始终拥有全部权限
+func StaffHasPermission(m *Staff, perm string) bool {
+	if m == nil {
+		return false
+	}
+	if m.Role == enums.RoleOwner {
+		return true
+	}
+	for _, v := range RolePermissionMatrix(m.Merchant.Id)[m.Role] {
+		if v == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissionSave 保存商户的角色权限表, 老板的权限不可修改
+func RolePermissionSave(merchantId int, matrix map[int][]string) error {
+	list := make([]*RolePermission, 0)
+	// 老板的权限一并写入, 以区分已配置和未配置
+	for _, perm := range enums.Permissions {
+		list = append(list, &RolePermission{MerchantId: merchantId, Role: enums.RoleOwner, Permission: perm})
+	}
+	for role, perms := range matrix {
+		if _, ok := enums.RoleNames[role]; !ok {
+			return errors.New("角色不存在")
+		}
+		if role == enums.RoleOwner {
+			continue
+		}
+		seen := make(map[string]bool, len(perms))
+		for _, perm := range perms {
+			if _, ok := enums.PermissionNames[perm]; !ok {
+				return errors.New("权限不存在: " + perm)
+			}
+			if seen[perm] {
+				continue
+			}
+			seen[perm] = true
+			list = append(list, &RolePermission{MerchantId: merchantId, Role: role, Permission: perm})
+		}
+	}
+
+	o := orm.NewOrm()
+	o.Begin()
+	if _, err := o.QueryTable(RolePermissionTBName()).Filter("merchant_id", merchantId).Delete(); err != nil {
+		o.Rollback()
+		return err
+	}
+	if _, err := o.InsertMulti(len(list), list); err != nil {
+		o.Rollback()
+		return err
+	}
+	return o.Commit()
+}
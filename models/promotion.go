@@ -0,0 +1,284 @@
+package models
+
+import (
+	"BossBar/enums"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// Promotion 优惠活动, 标记特惠台时关联到订台
+type Promotion struct {
+	Id         int       `json:"id"`
+	MerchantId int       `orm:"index" json:"-"`
+	Name       string    `orm:"size(64)" json:"name"`
+	Type       int       `json:"type"`                               //enums.Promo*
+	Value      float64   `orm:"digits(12);decimals(2)" json:"value"` //折扣百分比/赠送瓶数/减免金额
+	DrinkId    int       `json:"drink_id"`                           //赠送的酒水
+	StartDate  string    `orm:"size(10)" json:"start_date"`          //营业日范围, 含首尾
+	EndDate    string    `orm:"size(10)" json:"end_date"`
+	Sites      string    `orm:"size(500)" json:"sites"` //适用台位, 逗号分隔, 与区域都为空时不限
+	Zones      string    `orm:"size(255)" json:"zones"` //适用区域, 逗号分隔
+	UsageLimit int       `json:"usage_limit"`           //可用次数, 0为不限
+	UsedCount  int       `json:"used_count"`
+	Remark     string    `orm:"size(255)" json:"remark"`
+	Status     int       `orm:"default(1)" json:"-"`
+	CreateTime time.Time `orm:"auto_now_add;type(datetime)" json:"-"`
+	UpdateTime time.Time `orm:"auto_now;type(datetime)" json:"-"`
+}
+
+func (a *Promotion) TableName() string {
+	return PromotionTBName()
+}
+
+// TypeName 优惠类型名称
+func (a *Promotion) TypeName() string {
+	return enums.PromoTypeNames[a.Type]
+}
+
+// Active 营业日是否在活动期内
+func (a *Promotion) Active(bizDate string) bool {
+	return a.Status == enums.Enabled && bizDate >= a.StartDate && bizDate <= a.EndDate
+}
+
+// Exhausted 次数是否已用完
+func (a *Promotion) Exhausted() bool {
+	return a.UsageLimit > 0 && a.UsedCount >= a.UsageLimit
+}
+
+// 台位是否都在适用范围内
+func (a *Promotion) eligible(merchantId int, sites []string) bool {
+	allowSites, zones := splitList(a.Sites), splitList(a.Zones)
+	if len(allowSites) == 0 && len(zones) == 0 {
+		return true
+	}
+	var list []*BarSite
+	if len(zones) > 0 {
+		orm.NewOrm().QueryTable(BarSiteTBName()).Filter("merchant_id", merchantId).Filter("name__in", sites).All(&list)
+	}
+	for _, site := range sites {
+		ok := inList(allowSites, site)
+		for _, v := range list {
+			if !ok && v.Name == site && inList(zones, v.ZoneName()) {
+				ok = true
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func inList(list []string, item string) bool {
+	for _, v := range list {
+		if v == item {
+			return true
+		}
+	}
+	return false
+}
+
+// 活动对一单的效果: 减免后的最低消费和折扣金额
+func (a *Promotion) effect(minSpend, spend float64) (float64, float64) {
+	switch a.Type {
+	case enums.PromoPercent:
+		return minSpend, math.Round(spend*a.Value) / 100
+	case enums.PromoMinSpend:
+		return math.Max(0, minSpend-a.Value), 0
+	}
+	return minSpend, 0
+}
+
+// PromotionList 优惠活动列表; bizDate不为空时只返回当天可用于sites的活动
+func PromotionList(merchantId int, bizDate string, sites []string) []*Promotion {
+	var list []*Promotion
+	qs := orm.NewOrm().QueryTable(PromotionTBName()).Filter("merchant_id", merchantId).Filter("status", enums.Enabled)
+	if bizDate != "" {
+		qs = qs.Filter("start_date__lte", bizDate).Filter("end_date__gte", bizDate)
+	}
+	qs.OrderBy("-id").All(&list)
+	if bizDate == "" {
+		return list
+	}
+	available := make([]*Promotion, 0, len(list))
+	for _, v := range list {
+		if !v.Exhausted() && (len(sites) == 0 || v.eligible(merchantId, sites)) {
+			available = append(available, v)
+		}
+	}
+	return available
+}
+
+// 活动map id => promotion, 包括已删除的, 用于报表
+func promotionMap(merchantId int) map[int]*Promotion {
+	var list []*Promotion
+	orm.NewOrm().QueryTable(PromotionTBName()).Filter("merchant_id", merchantId).All(&list)
+	m := make(map[int]*Promotion, len(list))
+	for _, v := range list {
+		m[v.Id] = v
+	}
+	return m
+}
+
+// PromotionSave 新增或修改优惠活动
+func PromotionSave(merchantId int, m *Promotion) error {
+	m.Name = strings.TrimSpace(m.Name)
+	if m.Name == "" {
+		return errors.New("请填写活动名称")
+	}
+	if _, err := time.Parse("2006-01-02", m.StartDate); err != nil {
+		return errors.New("开始日期不正确")
+	}
+	if _, err := time.Parse("2006-01-02", m.EndDate); err != nil || m.EndDate < m.StartDate {
+		return errors.New("结束日期不正确")
+	}
+	switch m.Type {
+	case enums.PromoPercent:
+		if m.Value <= 0 || m.Value > 100 {
+			return errors.New("折扣比例须在0~100之间")
+		}
+	case enums.PromoFreeBottle:
+		if m.Value < 1 || m.Value != math.Trunc(m.Value) {
+			return errors.New("赠送瓶数不正确")
+		}
+		drink := Drink{Id: m.DrinkId}
+		if err := orm.NewOrm().Read(&drink); err != nil || drink.MerchantId != merchantId || drink.Status != enums.Enabled {
+			return errors.New("赠送的酒水不存在")
+		}
+	case enums.PromoMinSpend:
+		if m.Value <= 0 {
+			return errors.New("减免金额不正确")
+		}
+	default:
+		return errors.New("优惠类型不存在")
+	}
+	if m.UsageLimit < 0 {
+		return errors.New("可用次数不正确")
+	}
+	m.Sites = strings.Join(splitList(m.Sites), ",")
+	m.Zones = strings.Join(splitList(m.Zones), ",")
+
+	o := orm.NewOrm()
+	m.MerchantId = merchantId
+	m.Status = enums.Enabled
+	if m.Id == 0 {
+		m.UsedCount = 0
+		_, err := o.Insert(m)
+		return err
+	}
+	cur := Promotion{Id: m.Id}
+	if err := o.Read(&cur); err != nil || cur.MerchantId != merchantId || cur.Status != enums.Enabled {
+		return errors.New("活动不存在")
+	}
+	m.UsedCount = cur.UsedCount
+	_, err := o.Update(m, "Name", "Type", "Value", "DrinkId", "StartDate", "EndDate", "Sites", "Zones", "UsageLimit", "Remark", "UpdateTime")
+	return err
+}
+
+// PromotionDelete 删除优惠活动, 已关联的订台保留优惠
+func PromotionDelete(merchantId, id int) error {
+	num, err := orm.NewOrm().QueryTable(PromotionTBName()).Filter("merchant_id", merchantId).Filter("id", id).
+		Filter("status", enums.Enabled).Update(orm.Params{"status": enums.Deleted})
+	if err == nil && num == 0 {
+		return errors.New("活动不存在")
+	}
+	return err
+}
+
+// 一单订台使用活动: 占用次数, 赠酒时记为零元的预订酒水
+func promotionApply(o orm.Ormer, merchantId int, desks []*BarDesk, id, staffId int) error {
+	p := Promotion{Id: id}
+	if err := o.Read(&p); err != nil || p.MerchantId != merchantId || p.Status != enums.Enabled {
+		return errors.New("活动不存在")
+	}
+	first := desks[0]
+	sites := make([]string, 0, len(desks))
+	for _, v := range desks {
+		sites = append(sites, v.SiteName)
+	}
+	if !p.Active(first.BizDate) {
+		return fmt.Errorf("%s不在活动期内", p.Name)
+	}
+	if !p.eligible(merchantId, sites) {
+		return fmt.Errorf("%s不适用于%s", p.Name, strings.Join(sites, ","))
+	}
+	// 按已用次数条件更新, 同时使用时不会超出上限
+	qs := o.QueryTable(PromotionTBName()).Filter("id", p.Id)
+	if p.UsageLimit > 0 {
+		qs = qs.Filter("used_count__lt", p.UsageLimit)
+	}
+	num, err := qs.Update(orm.Params{"used_count": orm.ColValue(orm.ColAdd, 1)})
+	if err != nil {
+		return err
+	}
+	if num == 0 {
+		return fmt.Errorf("%s次数已用完", p.Name)
+	}
+	if p.Type != enums.PromoFreeBottle {
+		return nil
+	}
+	drink := Drink{Id: p.DrinkId}
+	if err := o.Read(&drink); err != nil {
+		return errors.New("赠送的酒水不存在")
+	}
+	_, err = o.Insert(&PreOrder{
+		MerchantId:  merchantId,
+		BizDate:     first.BizDate,
+		DeskId:      first.Id,
+		GroupId:     first.GroupId,
+		SiteName:    strings.Join(sites, ","),
+		DrinkId:     drink.Id,
+		DrinkName:   drink.Name,
+		BottleSize:  drink.BottleSize,
+		Quantity:    int(p.Value),
+		Status:      enums.PreOrderActive,
+		PromotionId: p.Id,
+		StaffId:     staffId,
+	})
+	return err
+}
+
+// 一单订台取消活动: 退回次数, 撤销赠酒
+func promotionRelease(o orm.Ormer, merchantId int, desks []*BarDesk) error {
+	id := desks[0].PromotionId
+	if id == 0 {
+		return nil
+	}
+	_, err := o.QueryTable(PromotionTBName()).Filter("id", id).Filter("used_count__gt", 0).
+		Update(orm.Params{"used_count": orm.ColValue(orm.ColMinus, 1)})
+	if err != nil {
+		return err
+	}
+	_, err = bookingPreOrders(o, merchantId, desks).Filter("promotion_id", id).
+		Update(orm.Params{"status": enums.PreOrderCancelled})
+	return err
+}
+
+// 转台后活动不适用于新台位时, 退回活动并取消特惠台标记
+func promotionRecheck(o orm.Ormer, merchantId int, desks []*BarDesk) error {
+	id := desks[0].PromotionId
+	if id == 0 {
+		return nil
+	}
+	sites := make([]string, 0, len(desks))
+	for _, v := range desks {
+		sites = append(sites, v.SiteName)
+	}
+	p := Promotion{Id: id}
+	if err := o.Read(&p); err == nil && p.eligible(merchantId, sites) {
+		return nil
+	}
+	if err := promotionRelease(o, merchantId, desks); err != nil {
+		return err
+	}
+	_, err := o.QueryTable(BarDeskTBName()).Filter("merchant_id", merchantId).Filter("id__in", barDeskIds(desks)).Update(orm.Params{
+		"status":       enums.DeskBooked,
+		"promotion_id": 0,
+	})
+	return err
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"BossBar/utils"
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/astaxie/beego"
+)
+
+// 订台弹窗打开时对台位的软锁定, 到期由过期监听自动释放
+const siteHoldPrefix = "site_hold:"
+
+func init() {
+	utils.RegisterExpireHandler(siteHoldPrefix, releaseExpiredHold)
+}
+
+func siteHoldKey(merchantId int, siteName string) string {
+	return fmt.Sprintf("%s%d:%s", siteHoldPrefix, merchantId, siteName)
+}
+
+// 商户当前锁定台位 site_name => 锁定人姓名, 锁定key中存锁定人标识
+func siteHoldsKey(merchantId int) string {
+	return fmt.Sprintf("site_holds:%d", merchantId)
+}
+
+// SiteHoldSeconds 锁定时长
+func SiteHoldSeconds() int {
+	seconds, err := beego.AppConfig.Int("listener::hold_seconds")
+	if err != nil || seconds <= 0 {
+		seconds = 120
+	}
+	return seconds
+}
+
+// HoldSite 锁定台位, 已被他人锁定时返回错误, 本人重复锁定则续期
+// holder为锁定人标识(员工id), 同名员工互不影响; holderName用于展示
+func HoldSite(merchantId int, siteName, holder, holderName string) error {
+	key := siteHoldKey(merchantId, siteName)
+	timeout := SiteHoldSeconds()
+	// SET NX原子抢占, 抢占失败时只有本人持有才续期
+	err := utils.SetExpireCache(key, holder, timeout, true)
+	if err != nil {
+		cur, getErr := utils.GetPureCache(key)
+		if getErr == nil && cur != holder {
+			name, _ := utils.HGetCache(siteHoldsKey(merchantId), siteName)
+			return errors.New(siteName + "正在被" + name + "预定中")
+		}
+		if getErr == nil && utils.RenewExpireCache(key, timeout) {
+			err = nil
+		} else {
+			// 续期前刚好过期, 重新抢占
+			err = utils.SetExpireCache(key, holder, timeout, true)
+		}
+	}
+	if err != nil {
+		log.Errorf("HoldSite failed, key:%s, err:%s", key, err.Error())
+		return errors.New("锁定台位失败")
+	}
+	utils.HSetCache(siteHoldsKey(merchantId), siteName, holderName)
+	return nil
+}
+
+// ReleaseSite 主动释放锁定, 只能释放本人的锁定
+func ReleaseSite(merchantId int, siteName, holder string) {
+	key := siteHoldKey(merchantId, siteName)
+	if cur, err := utils.GetPureCache(key); err != nil || cur != holder {
+		return
+	}
+	utils.DelCache(key)
+	utils.UnwatchExpire(key)
+	utils.HDelCache(siteHoldsKey(merchantId), siteName)
+}
+
+// GetSiteHolds 商户当前所有锁定 site_name => 锁定人姓名
+func GetSiteHolds(merchantId int) map[string]string {
+	holds := make(map[string]string)
+	pairs, err := utils.HGetAllCache(siteHoldsKey(merchantId))
+	if err != nil {
+		return holds
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		holds[pairs[i]] = pairs[i+1]
+	}
+	return holds
+}
+
+func releaseExpiredHold(key string) {
+	merchantId, siteName := utils.SplitExpireKey(key, siteHoldPrefix)
+	if siteName == "" {
+		return
+	}
+	utils.HDelCache(siteHoldsKey(merchantId), siteName)
+	log.Infof("[listener] site hold released, merchant:%d, site:%s", merchantId, siteName)
+}
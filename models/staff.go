@@ -0,0 +1,125 @@
+package models
+
+import (
+	"BossBar/enums"
+	"BossBar/utils"
+	"errors"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// Staff 商户下的员工账号, 每人独立登录
+type Staff struct {
+	Id            int
+	Merchant      *Merchant `orm:"rel(fk)" json:"-"`
+	UserName      string    `orm:"size(32);unique" json:"user_name"`
+	RealName      string    `orm:"size(32)" json:"real_name"`
+	Password      string    `orm:"size(128)" json:"-"`
+	Role          int       `json:"role"`
+	Status        int       `orm:"default(1)" json:"status"`
+	CreateTime    time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+	LastLoginTime time.Time `orm:"null;type(datetime)" json:"last_login_time"`
+	TotpSecret    string    `orm:"size(64)" json:"-"`    //动态验证码密钥
+	TotpEnabled   bool      `json:"totp_enabled"`        //已完成绑定
+	RecoveryCodes string    `orm:"size(1024)" json:"-"`  //恢复码哈希, 逗号分隔
+	Pin           string    `orm:"size(128)" json:"-"`   //平板登录PIN哈希
+	TeamId        int       `orm:"index" json:"team_id"` //所在团队
+}
+
+func (a *Staff) TableName() string {
+	return StaffTBName()
+}
+
+// RoleName 角色名称
+func (a *Staff) RoleName() string {
+	return enums.RoleNames[a.Role]
+}
+
+// IsManager 老板或经理
+func (a *Staff) IsManager() bool {
+	return a.Role == enums.RoleOwner || a.Role == enums.RoleManager
+}
+
+// CanManage 是否可以管理目标员工, 经理不能管理老板和其他经理
+func (a *Staff) CanManage(target *Staff) bool {
+	if a.Role == enums.RoleOwner {
+		return true
+	}
+	return a.Role == enums.RoleManager && !target.IsManager()
+}
+
+// StaffOne 根据id获取单条, 同时带出商户
+func StaffOne(id int) (*Staff, error) {
+	m := Staff{}
+	err := orm.NewOrm().QueryTable(StaffTBName()).Filter("id", id).RelatedSel().One(&m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// StaffOneByUserName 根据用户名和密码获取单条, 旧哈希在校验通过后自动升级
+func StaffOneByUserName(username, userpwd string) (*Staff, error) {
+	m := Staff{}
+	err := orm.NewOrm().QueryTable(StaffTBName()).Filter("user_name", username).RelatedSel().One(&m)
+	if err != nil {
+		utils.CheckDummyPassword(userpwd)
+		return nil, err
+	}
+	ok, rehash := utils.CheckPassword(m.Password, userpwd)
+	if !ok {
+		return nil, errors.New("密码错误")
+	}
+	if m.Status != enums.Enabled {
+		return nil, errors.New("账号已禁用")
+	}
+	if rehash {
+		if hashed, err := utils.HashPassword(userpwd); err == nil {
+			m.Password = hashed
+			orm.NewOrm().Update(&m, "Password")
+		}
+	}
+	return &m, nil
+}
+
+// StaffList 商户下的员工列表
+func StaffList(merchantId int) []*Staff {
+	var list []*Staff
+	orm.NewOrm().QueryTable(StaffTBName()).Filter("merchant_id", merchantId).Exclude("status", enums.Deleted).OrderBy("role", "id").All(&list)
+	return list
+}
+
+// StaffLogin 记录登录时间
+func StaffLogin(m *Staff) {
+	m.LastLoginTime = time.Now()
+	orm.NewOrm().Update(m, "LastLoginTime")
+}
+
+// StaffSave 新增或修改员工, password为空时不修改密码
+func StaffSave(m *Staff, password string) error {
+	if _, ok := enums.RoleNames[m.Role]; !ok {
+		return errors.New("角色不存在")
+	}
+	o := orm.NewOrm()
+	if exist := (Staff{}); o.QueryTable(StaffTBName()).Filter("user_name", m.UserName).Exclude("id", m.Id).One(&exist) == nil {
+		return errors.New("用户名已存在")
+	}
+	fields := []string{"RealName", "Role", "Status"}
+	var err error
+	if password != "" {
+		if m.Password, err = utils.HashPassword(password); err != nil {
+			return err
+		}
+		fields = append(fields, "Password")
+	}
+	if m.Id == 0 {
+		if password == "" {
+			return errors.New("密码不能为空")
+		}
+		_, err = o.Insert(m)
+	} else {
+		_, err = o.Update(m, fields...)
+	}
+	return err
+}
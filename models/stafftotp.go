@@ -0,0 +1,153 @@
+package models
+
+import (
+	"BossBar/utils"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/astaxie/beego/orm"
+)
+
+const (
+	recoveryCodeCount = 10  //一次生成的恢复码数量
+	totpMaxFails      = 5   //动态码连续错误次数上限
+	totpLockTime      = 900 //动态码错误过多后锁定时长(秒)
+)
+
+func totpFailKey(staffId int) string {
+	return fmt.Sprintf("totp_fail:%d", staffId)
+}
+
+// StaffTotpEnroll 生成新的动态验证码密钥, 需用动态码激活后才生效
+func StaffTotpEnroll(m *Staff) (secret, uri string, err error) {
+	if m.TotpEnabled {
+		return "", "", errors.New("已绑定动态验证码, 请先解绑")
+	}
+	if secret, err = utils.GenerateTotpSecret(); err != nil {
+		return "", "", err
+	}
+	m.TotpSecret = secret
+	if _, err = orm.NewOrm().Update(m, "TotpSecret"); err != nil {
+		return "", "", err
+	}
+	return secret, utils.TotpProvisioningUri(secret, m.UserName), nil
+}
+
+// StaffTotpActivate 校验首个动态码后启用, 返回恢复码明文(只展示一次)
+func StaffTotpActivate(m *Staff, code string) ([]string, error) {
+	if m.TotpEnabled {
+		return nil, errors.New("已绑定动态验证码")
+	}
+	if m.TotpSecret == "" {
+		return nil, errors.New("请先获取绑定二维码")
+	}
+	if _, ok := utils.ValidateTotp(m.TotpSecret, code); !ok {
+		return nil, errors.New("动态验证码错误")
+	}
+	return staffResetRecoveryCodes(m, "TotpEnabled")
+}
+
+// StaffTotpRecoveryCodes 重新生成恢复码, 旧恢复码作废
+func StaffTotpRecoveryCodes(m *Staff, code string) ([]string, error) {
+	if err := StaffVerifyTotp(m, code); err != nil {
+		return nil, err
+	}
+	return staffResetRecoveryCodes(m)
+}
+
+func staffResetRecoveryCodes(m *Staff, fields ...string) ([]string, error) {
+	codes, hashes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	m.TotpEnabled = true
+	m.RecoveryCodes = strings.Join(hashes, ",")
+	if _, err = orm.NewOrm().Update(m, append(fields, "RecoveryCodes")...); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// StaffTotpDisable 解绑动态验证码
+func StaffTotpDisable(m *Staff, code string) error {
+	if err := StaffVerifyTotp(m, code); err != nil {
+		return err
+	}
+	m.TotpSecret, m.TotpEnabled, m.RecoveryCodes = "", false, ""
+	_, err := orm.NewOrm().Update(m, "TotpSecret", "TotpEnabled", "RecoveryCodes")
+	return err
+}
+
+// StaffVerifyTotp 校验动态码或恢复码, 同一动态码只能使用一次, 恢复码用后作废
+// 连续错误totpMaxFails次后锁定totpLockTime秒
+func StaffVerifyTotp(m *Staff, code string) error {
+	if !m.TotpEnabled {
+		return errors.New("请先绑定动态验证码")
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return errors.New("请输入动态验证码")
+	}
+	failKey := totpFailKey(m.Id)
+	fails, _ := utils.GetPureCache(failKey)
+	if n, _ := strconv.Atoi(fails); n >= totpMaxFails {
+		return errors.New("动态验证码错误次数过多, 请稍后再试")
+	}
+	if step, ok := utils.ValidateTotp(m.TotpSecret, code); ok {
+		if utils.SetNxExCache(fmt.Sprintf("totp_used:%d:%d", m.Id, step), "1", 120) != nil {
+			return errors.New("动态验证码已使用, 请等待下一个")
+		}
+		utils.DelCache(failKey)
+		return nil
+	}
+
+	ok, err := staffUseRecoveryCode(m, utils.HashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if ok {
+		utils.DelCache(failKey)
+		return nil
+	}
+	utils.IncrByCache(failKey, 1)
+	utils.ExpireCache(failKey, totpLockTime)
+	return errors.New("动态验证码错误")
+}
+
+// 使用恢复码, 按原值条件更新, 同一恢复码并发使用时只有一个成功
+func staffUseRecoveryCode(m *Staff, hashed string) (bool, error) {
+	o := orm.NewOrm()
+	for retry := 0; retry < 3; retry++ {
+		hashes := strings.Split(m.RecoveryCodes, ",")
+		rest := make([]string, 0, len(hashes))
+		found := false
+		for _, v := range hashes {
+			if v != "" && v == hashed {
+				found = true
+			} else if v != "" {
+				rest = append(rest, v)
+			}
+		}
+		if !found {
+			return false, nil
+		}
+		num, err := o.QueryTable(StaffTBName()).Filter("id", m.Id).Filter("recovery_codes", m.RecoveryCodes).
+			Update(orm.Params{"recovery_codes": strings.Join(rest, ",")})
+		if err != nil {
+			return false, err
+		}
+		if num > 0 {
+			m.RecoveryCodes = strings.Join(rest, ",")
+			return true, nil
+		}
+		// 恢复码已被其他请求修改, 重新读取后再试
+		cur := Staff{Id: m.Id}
+		if err := o.Read(&cur); err != nil {
+			return false, err
+		}
+		m.RecoveryCodes = cur.RecoveryCodes
+	}
+	return false, nil
+}
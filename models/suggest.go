@@ -0,0 +1,220 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// SiteSuggestion 推荐的空台或相邻拼台组合
+type SiteSuggestion struct {
+	Sites    []string `json:"sites"`
+	Zone     string   `json:"zone"`
+	MinCap   int      `json:"min_capacity"`
+	MaxCap   int      `json:"max_capacity"`
+	Combined bool     `json:"combined"` //是否拼台
+	zoneHit  bool
+}
+
+// ZoneName 台位所属区域, 未设置时按高亮颜色分区
+func (a *BarSite) ZoneName() string {
+	if a.Zone != "" {
+		return a.Zone
+	}
+	return a.Class
+}
+
+// Capacity 台位可坐人数范围
+func (a *BarSite) Capacity() (int, int) {
+	min, max := a.MinCap, a.MaxCap
+	if max <= 0 {
+		max = beego.AppConfig.DefaultInt("site::default_capacity", 6)
+	}
+	if min <= 0 {
+		min = 1
+	}
+	return min, max
+}
+
+// 是否可订的台位, 平面图上的功能按钮没有高亮颜色
+func (a *BarSite) bookable() bool {
+	return a.Class != ""
+}
+
+// 热区在平面图上的外接矩形
+func (a *BarSite) bounds() (x1, y1, x2, y2 float64, ok bool) {
+	var nums []float64
+	for _, v := range strings.Split(a.Coords, ",") {
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, 0, 0, 0, false
+		}
+		nums = append(nums, n)
+	}
+	if a.Shape == "circle" {
+		if len(nums) != 3 {
+			return 0, 0, 0, 0, false
+		}
+		return nums[0] - nums[2], nums[1] - nums[2], nums[0] + nums[2], nums[1] + nums[2], true
+	}
+	if len(nums) < 4 || len(nums)%2 != 0 {
+		return 0, 0, 0, 0, false
+	}
+	x1, y1, x2, y2 = math.MaxFloat64, math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64
+	for i := 0; i < len(nums); i += 2 {
+		x1, x2 = math.Min(x1, nums[i]), math.Max(x2, nums[i])
+		y1, y2 = math.Min(y1, nums[i+1]), math.Max(y2, nums[i+1])
+	}
+	return x1, y1, x2, y2, true
+}
+
+// 两台在平面图上的间距不超过gap视为相邻
+func sitesAdjacent(a, b *BarSite, gap float64) bool {
+	ax1, ay1, ax2, ay2, ok := a.bounds()
+	if !ok {
+		return false
+	}
+	bx1, by1, bx2, by2, ok := b.bounds()
+	if !ok {
+		return false
+	}
+	dx := math.Max(0, math.Max(bx1-ax2, ax1-bx2))
+	dy := math.Max(0, math.Max(by1-ay2, ay1-by2))
+	return dx <= gap && dy <= gap
+}
+
+// 人数须在台位容量范围内, 拼台时与推荐一致: 每张台至少坐一人, 最多为各台容量之和
+func checkPartySize(merchantId int, names []string, party int) error {
+	if party <= 0 {
+		return nil
+	}
+	var list []*BarSite
+	orm.NewOrm().QueryTable(BarSiteTBName()).Filter("merchant_id", merchantId).Filter("name__in", names).All(&list)
+	seen := make(map[string]bool, len(list))
+	sites := make([]*BarSite, 0, len(list))
+	for _, v := range list {
+		if !seen[v.Name] {
+			seen[v.Name] = true
+			sites = append(sites, v)
+		}
+	}
+	m := newSiteSuggestion(sites, "")
+	if party > m.MaxCap {
+		return fmt.Errorf("人数超过台位容量, 最多%d人", m.MaxCap)
+	}
+	if party < m.MinCap {
+		return fmt.Errorf("人数少于台位最低人数, 至少%d人", m.MinCap)
+	}
+	return nil
+}
+
+// 时段内空闲的可订台位, 已被预定或正在被锁定的除外
+func freeBarSites(merchantId, layout int, start, end time.Time) []*BarSite {
+	var list []*BarDesk
+	liveBarDesks(orm.NewOrm(), merchantId).Filter("arrive_time__lt", end).Filter("arrive_time__gt", start.Add(-MaxDeskDuration*time.Minute)).All(&list)
+	busy := GetSiteHolds(merchantId)
+	for _, v := range list {
+		if v.Overlaps(start, end) {
+			busy[v.SiteName] = ""
+		}
+	}
+	free := make([]*BarSite, 0)
+	for name, site := range BarSiteMap(merchantId, layout) {
+		if _, ok := busy[name]; !ok && site.bookable() {
+			free = append(free, site)
+		}
+	}
+	sort.Slice(free, func(i, j int) bool {
+		return free[i].Name < free[j].Name
+	})
+	return free
+}
+
+// 三台之间至少有两条相邻关系才连成一片
+func connected(ab, ac, bc bool) bool {
+	n := 0
+	for _, v := range []bool{ab, ac, bc} {
+		if v {
+			n++
+		}
+	}
+	return n >= 2
+}
+
+func newSiteSuggestion(sites []*BarSite, zone string) *SiteSuggestion {
+	m := &SiteSuggestion{Combined: len(sites) > 1, zoneHit: true}
+	for i, v := range sites {
+		min, max := v.Capacity()
+		m.Sites = append(m.Sites, v.Name)
+		m.MinCap += min
+		m.MaxCap += max
+		if i == 0 {
+			m.Zone = v.ZoneName()
+		} else if m.Zone != v.ZoneName() {
+			m.Zone = ""
+		}
+		if zone != "" && v.ZoneName() != zone {
+			m.zoneHit = false
+		}
+	}
+	// 拼台时每张台至少坐一人即可
+	if m.Combined {
+		m.MinCap = len(sites)
+	}
+	return m
+}
+
+// SuggestSites 按人数推荐时段内的空台和最多三台的相邻拼台;
+// 优先指定区域, 其次台数少、空位少的
+func SuggestSites(merchantId, layout int, start, end time.Time, party int, zone string, limit int) []*SiteSuggestion {
+	free := freeBarSites(merchantId, layout, start, end)
+	gap := float64(beego.AppConfig.DefaultInt("site::adjacent_gap", 12))
+	adjacent := make([][]bool, len(free))
+	for i := range free {
+		adjacent[i] = make([]bool, len(free))
+		for j := range free {
+			adjacent[i][j] = i != j && sitesAdjacent(free[i], free[j], gap)
+		}
+	}
+
+	list := make([]*SiteSuggestion, 0)
+	add := func(sites ...*BarSite) {
+		if m := newSiteSuggestion(sites, zone); party >= m.MinCap && party <= m.MaxCap {
+			list = append(list, m)
+		}
+	}
+	// 单台、相邻两台、三台中至少两两相连
+	for i := range free {
+		add(free[i])
+		for j := i + 1; j < len(free); j++ {
+			if adjacent[i][j] {
+				add(free[i], free[j])
+			}
+			for k := j + 1; k < len(free); k++ {
+				if connected(adjacent[i][j], adjacent[i][k], adjacent[j][k]) {
+					add(free[i], free[j], free[k])
+				}
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.zoneHit != b.zoneHit {
+			return a.zoneHit
+		}
+		if len(a.Sites) != len(b.Sites) {
+			return len(a.Sites) < len(b.Sites)
+		}
+		return a.MaxCap < b.MaxCap
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	return list
+}
@@ -0,0 +1,275 @@
+package models
+
+import (
+	"BossBar/conf"
+	"BossBar/enums"
+	"BossBar/utils"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+)
+
+// Team 订台团队, 队长邀请队友加入, 队友的订台计入团队业绩
+type Team struct {
+	Id         int       `json:"id"`
+	MerchantId int       `orm:"index" json:"-"`
+	Name       string    `orm:"size(32)" json:"name"`
+	LeaderId   int       `json:"leader_id"`
+	Status     int       `orm:"default(1)" json:"status"`
+	CreateTime time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+}
+
+func (a *Team) TableName() string {
+	return TeamTBName()
+}
+
+// CanManage 队长和经理可管理团队
+func (a *Team) CanManage(m *Staff) bool {
+	return m.Id == a.LeaderId || (m.IsManager() && m.Merchant.Id == a.MerchantId)
+}
+
+// TeamStat 团队业绩, 按队友汇总
+type TeamStat struct {
+	Period  string             `json:"period"`
+	Members []*TeamMemberStat  `json:"members"`
+	Totals  map[string]float64 `json:"totals"` //metric => 合计
+}
+
+// TeamMemberStat 队友业绩
+type TeamMemberStat struct {
+	StaffId   int                `json:"staff_id"`
+	StaffName string             `json:"staff_name"`
+	IsLeader  bool               `json:"is_leader"`
+	Scores    map[string]float64 `json:"scores"`
+}
+
+// 待使用的邀请码, 按邀请码缓存
+type teamInvite struct {
+	TeamId     int
+	MerchantId int
+	InviterId  int
+}
+
+func teamInviteTimeout() int {
+	return beego.AppConfig.DefaultInt("team::invite_timeout", 86400)
+}
+
+func teamInviteKey(code string) string {
+	return "team_invite:" + code
+}
+
+// TeamCreate 创建团队, 创建人为队长
+func TeamCreate(leader *Staff, name string) (*Team, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 32 {
+		return nil, errors.New("团队名称不正确")
+	}
+	if leader.TeamId > 0 {
+		return nil, errors.New("你已在团队中")
+	}
+	m := &Team{MerchantId: leader.Merchant.Id, Name: name, LeaderId: leader.Id, Status: enums.Enabled}
+	o := orm.NewOrm()
+	o.Begin()
+	if _, err := o.Insert(m); err != nil {
+		o.Rollback()
+		return nil, err
+	}
+	leader.TeamId = m.Id
+	if _, err := o.Update(leader, "TeamId"); err != nil {
+		o.Rollback()
+		return nil, err
+	}
+	return m, o.Commit()
+}
+
+// TeamOne 获取商户下有效的团队
+func TeamOne(merchantId, id int) (*Team, error) {
+	m := Team{Id: id}
+	if err := orm.NewOrm().Read(&m); err != nil || m.MerchantId != merchantId || m.Status != enums.Enabled {
+		return nil, errors.New("团队不存在")
+	}
+	return &m, nil
+}
+
+// TeamMembers 团队成员, 队长在前
+func TeamMembers(m *Team) []*Staff {
+	var list []*Staff
+	orm.NewOrm().QueryTable(StaffTBName()).Filter("merchant_id", m.MerchantId).Filter("team_id", m.Id).
+		Filter("status", enums.Enabled).OrderBy("id").All(&list)
+	members := make([]*Staff, 0, len(list))
+	for _, v := range list {
+		if v.Id == m.LeaderId {
+			members = append([]*Staff{v}, members...)
+		} else {
+			members = append(members, v)
+		}
+	}
+	return members
+}
+
+func teamMemberIds(m *Team) []int {
+	members := TeamMembers(m)
+	ids := make([]int, 0, len(members))
+	for _, v := range members {
+		ids = append(ids, v.Id)
+	}
+	return ids
+}
+
+// TeamInvite 生成邀请码, 有效期内可多人使用
+func TeamInvite(operator *Staff, m *Team) (string, int, error) {
+	if !m.CanManage(operator) {
+		return "", 0, errors.New("只有队长可以邀请队友")
+	}
+	timeout := teamInviteTimeout()
+	invite := teamInvite{TeamId: m.Id, MerchantId: m.MerchantId, InviterId: operator.Id}
+	for i := 0; i < 3; i++ {
+		code := strings.ToUpper(utils.SecureRandomString(8))
+		if exists, _ := utils.ExistsCache(teamInviteKey(code)); exists {
+			continue
+		}
+		if err := utils.SetCache(teamInviteKey(code), invite, timeout); err != nil {
+			return "", 0, err
+		}
+		return code, timeout, nil
+	}
+	return "", 0, errors.New("生成邀请码失败, 请重试")
+}
+
+// TeamJoin 使用邀请码加入团队
+func TeamJoin(staff *Staff, code string) (*Team, error) {
+	var invite teamInvite
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" || utils.GetCache(teamInviteKey(code), &invite) != nil || invite.MerchantId != staff.Merchant.Id {
+		return nil, errors.New("邀请码错误或已过期")
+	}
+	if staff.TeamId > 0 {
+		return nil, errors.New("你已在团队中, 请先退出")
+	}
+	m, err := TeamOne(staff.Merchant.Id, invite.TeamId)
+	if err != nil {
+		return nil, err
+	}
+	staff.TeamId = m.Id
+	if _, err := orm.NewOrm().Update(staff, "TeamId"); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// TeamRemove 移出队友, staffId为本人时为退出团队; 队长不能退出, 只能解散
+func TeamRemove(operator *Staff, m *Team, staffId int) error {
+	if staffId != operator.Id && !m.CanManage(operator) {
+		return errors.New("只有队长可以移出队友")
+	}
+	if staffId == m.LeaderId {
+		return errors.New("队长不能退出团队")
+	}
+	num, err := orm.NewOrm().QueryTable(StaffTBName()).Filter("id", staffId).Filter("team_id", m.Id).Update(orm.Params{
+		"team_id": 0,
+	})
+	if err == nil && num == 0 {
+		return errors.New("不是该团队的成员")
+	}
+	return err
+}
+
+// TeamDissolve 解散团队, 成员全部退出
+func TeamDissolve(operator *Staff, m *Team) error {
+	if !m.CanManage(operator) {
+		return errors.New("只有队长可以解散团队")
+	}
+	o := orm.NewOrm()
+	o.Begin()
+	if _, err := o.QueryTable(TeamTBName()).Filter("id", m.Id).Update(orm.Params{"status": enums.Deleted}); err != nil {
+		o.Rollback()
+		return err
+	}
+	if _, err := o.QueryTable(StaffTBName()).Filter("team_id", m.Id).Update(orm.Params{"team_id": 0}); err != nil {
+		o.Rollback()
+		return err
+	}
+	return o.Commit()
+}
+
+// TeamStats 团队某周期的业绩, period为营业日或月份
+func TeamStats(m *Team, period string) *TeamStat {
+	members := TeamMembers(m)
+	ids := make([]int, 0, len(members))
+	for _, v := range members {
+		ids = append(ids, v.Id)
+	}
+	stat := &TeamStat{Period: period, Members: make([]*TeamMemberStat, 0, len(members)), Totals: make(map[string]float64)}
+	if len(ids) == 0 {
+		return stat
+	}
+	scores := rankAggregate(m.MerchantId, period, ids)
+	for _, v := range members {
+		ms := &TeamMemberStat{StaffId: v.Id, StaffName: v.RealName, IsLeader: v.Id == m.LeaderId, Scores: make(map[string]float64)}
+		for metric := range RankNames {
+			score := scores[metric][v.Id]
+			if metric == RankSpend {
+				score /= 100
+			}
+			ms.Scores[metric] = score
+			stat.Totals[metric] += score
+		}
+		stat.Members = append(stat.Members, ms)
+	}
+	return stat
+}
+
+// TeamDesks 团队成员在营业日的订台
+func TeamDesks(m *Team, bizDate string) []*BarDesk {
+	var list []*BarDesk
+	ids := teamMemberIds(m)
+	if len(ids) == 0 {
+		return list
+	}
+	liveBarDesks(orm.NewOrm(), m.MerchantId).Filter("biz_date", bizDate).Filter("reserve_staff_id__in", ids).
+		OrderBy("arrive_time", "id").All(&list)
+	for _, v := range list {
+		v.Arrive = v.ArriveTime.Format(conf.ClockLayout)
+	}
+	return list
+}
+
+// TeamAssign 队长把团队的订台转给另一名队友
+func TeamAssign(operator *Staff, m *Team, desks []*BarDesk, staffId int) error {
+	if !m.CanManage(operator) {
+		return errors.New("只有队长可以分配订台")
+	}
+	if len(desks) == 0 {
+		return errors.New("订台信息不存在")
+	}
+	var to *Staff
+	members := TeamMembers(m)
+	inTeam := make(map[int]bool, len(members))
+	for _, v := range members {
+		inTeam[v.Id] = true
+		if v.Id == staffId {
+			to = v
+		}
+	}
+	if to == nil {
+		return errors.New("不是该团队的成员")
+	}
+	for _, v := range desks {
+		if !inTeam[v.ReserveStaffId] {
+			return errors.New(v.SiteName + "不是团队的订台")
+		}
+	}
+	_, err := orm.NewOrm().QueryTable(BarDeskTBName()).Filter("merchant_id", m.MerchantId).Filter("id__in", barDeskIds(desks)).Update(orm.Params{
+		"reserve_staff_id": to.Id,
+		"reserve_name":     to.RealName,
+		"update_time":      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	rankReset(m.MerchantId, desks[0].BizDate)
+	return nil
+}
@@ -0,0 +1,145 @@
+package models
+
+import (
+	"BossBar/enums"
+	"errors"
+	"time"
+
+	"github.com/astaxie/beego/orm"
+)
+
+// WaitEntry 满台时的等位登记, 按营业日排队, 转为订台后保留记录
+type WaitEntry struct {
+	Id            int       `json:"id"`
+	MerchantId    int       `json:"-"`
+	BizDate       string    `orm:"size(10)" json:"biz_date"`
+	CustomerName  string    `orm:"size(32)" json:"customer_name"`
+	CustomerPhone string    `orm:"size(32)" json:"customer_phone"`
+	PartySize     int       `json:"party_size"`  //人数
+	QuotedWait    int       `json:"quoted_wait"` //告知客人的预计等待(分钟)
+	Remark        string    `orm:"size(255)" json:"remark"`
+	Status        int       `json:"status"`
+	SiteName      string    `orm:"size(32)" json:"site_name"` //通知或入座的台位
+	DeskId        int       `json:"desk_id"`                  //转成的订台
+	StaffId       int       `json:"-"`                        //最后操作人
+	OfferTime     time.Time `orm:"null;type(datetime)" json:"offer_time"`
+	CreateTime    time.Time `orm:"auto_now_add;type(datetime)" json:"create_time"`
+	UpdateTime    time.Time `orm:"auto_now;type(datetime)" json:"update_time"`
+	Position      int       `orm:"-" json:"position"` //当前排位, 从1开始
+}
+
+func (a *WaitEntry) TableName() string {
+	return WaitEntryTBName()
+}
+
+func (a *WaitEntry) TableIndex() [][]string {
+	return [][]string{
+		{"MerchantId", "BizDate", "Status"},
+	}
+}
+
+// WaitlistAdd 登记等位, 排在队尾
+func WaitlistAdd(m *WaitEntry) error {
+	if m.PartySize <= 0 {
+		return errors.New("人数不能为空")
+	}
+	if m.CustomerName == "" && m.CustomerPhone == "" {
+		return errors.New("客户姓名和手机不能都为空")
+	}
+	m.Status = enums.WaitWaiting
+	_, err := orm.NewOrm().Insert(m)
+	return err
+}
+
+// WaitlistList 营业日的等位队列, 排队中的按登记顺序在前, 其后是历史记录
+func WaitlistList(merchantId int, bizDate string) []*WaitEntry {
+	var list []*WaitEntry
+	orm.NewOrm().QueryTable(WaitEntryTBName()).Filter("merchant_id", merchantId).Filter("biz_date", bizDate).OrderBy("id").All(&list)
+	queue := make([]*WaitEntry, 0, len(list))
+	history := make([]*WaitEntry, 0)
+	for _, v := range list {
+		if v.Status == enums.WaitWaiting || v.Status == enums.WaitOffered {
+			v.Position = len(queue) + 1
+			queue = append(queue, v)
+		} else {
+			history = append(history, v)
+		}
+	}
+	return append(queue, history...)
+}
+
+// WaitlistNext 队列中下一位仍在等位的客人, 没有时返回nil
+func WaitlistNext(merchantId int, bizDate string) *WaitEntry {
+	m := WaitEntry{}
+	err := orm.NewOrm().QueryTable(WaitEntryTBName()).Filter("merchant_id", merchantId).Filter("biz_date", bizDate).
+		Filter("status", enums.WaitWaiting).OrderBy("id").One(&m)
+	if err != nil {
+		return nil
+	}
+	return &m
+}
+
+// WaitEntryOne 获取商户下的等位记录
+func WaitEntryOne(merchantId, id int) (*WaitEntry, error) {
+	m := WaitEntry{Id: id}
+	if err := orm.NewOrm().Read(&m); err != nil || m.MerchantId != merchantId {
+		return nil, errors.New("等位记录不存在")
+	}
+	return &m, nil
+}
+
+func waitEntryActive(m *WaitEntry) error {
+	if m.Status != enums.WaitWaiting && m.Status != enums.WaitOffered {
+		return errors.New("该客人已" + enums.WaitStatusNames[m.Status])
+	}
+	return nil
+}
+
+// WaitlistOffer 通知客人有台
+func WaitlistOffer(m *WaitEntry, siteName string, staffId int) error {
+	if err := waitEntryActive(m); err != nil {
+		return err
+	}
+	m.Status = enums.WaitOffered
+	m.SiteName = siteName
+	m.StaffId = staffId
+	m.OfferTime = time.Now()
+	_, err := orm.NewOrm().Update(m, "Status", "SiteName", "StaffId", "OfferTime", "UpdateTime")
+	return err
+}
+
+// WaitlistConvert 等位客人入座, 转为台位的订台, 等位记录保留
+func WaitlistConvert(m *WaitEntry, siteName string, desk BarDesk) error {
+	if err := waitEntryActive(m); err != nil {
+		return err
+	}
+	desk.CustomerName = m.CustomerName
+	desk.CustomerPhone = m.CustomerPhone
+	if desk.Remark == "" {
+		desk.Remark = m.Remark
+	}
+	if err := BarDeskOrder(m.MerchantId, []string{siteName}, desk); err != nil {
+		return err
+	}
+	created := BarDesk{}
+	if orm.NewOrm().QueryTable(BarDeskTBName()).Filter("merchant_id", m.MerchantId).Filter("site_name", siteName).
+		Filter("arrive_time", desk.ArriveTime).One(&created) == nil {
+		m.DeskId = created.Id
+	}
+	m.Status = enums.WaitSeated
+	m.SiteName = siteName
+	m.StaffId = desk.StaffId
+	_, err := orm.NewOrm().Update(m, "Status", "SiteName", "DeskId", "StaffId", "UpdateTime")
+	return err
+}
+
+// WaitlistLeave 客人离开或放弃等位
+func WaitlistLeave(m *WaitEntry, staffId int) error {
+	if err := waitEntryActive(m); err != nil {
+		return err
+	}
+	m.Status = enums.WaitLeft
+	m.StaffId = staffId
+	_, err := orm.NewOrm().Update(m, "Status", "StaffId", "UpdateTime")
+	return err
+}
@@ -2,11 +2,109 @@ package routers
 
 import (
 	"BossBar/controllers"
+	"BossBar/filters"
 
 	"github.com/astaxie/beego"
 )
 
 func init() {
+	beego.InsertFilter("/*", beego.BeforeRouter, filters.AuthFilter)
+	beego.InsertFilter("/*", beego.BeforeRouter, filters.XsrfFilter)
+	beego.InsertFilter("/*", beego.BeforeRouter, filters.PermissionFilter)
+	beego.InsertFilter(filters.PartnerApiPrefix+"*", beego.BeforeRouter, filters.PartnerFilter)
+
+	beego.Router("/", &controllers.BarController{}, "Get:Index")
 	beego.Router("/login", &controllers.BaseController{}, "Post:Login")
-	beego.Router("/register", &controllers.BaseController{}, "Post:Register")
+	beego.Router("/logout", &controllers.BaseController{}, "Post:Logout")
+	beego.Router("/token/refresh", &controllers.BaseController{}, "Post:Refresh")
+	beego.Router("/.well-known/jwks.json", &controllers.BaseController{}, "Get:Jwks")
+	beego.Router("/xsrf/token", &controllers.BaseController{}, "Get:XsrfToken")
+	beego.Router("/register", &controllers.RegisterController{}, "Post:Register")
+	beego.Router("/register/code", &controllers.RegisterController{}, "Post:Code")
+
+	beego.Router("/order", &controllers.BarController{}, "Post:Order")
+	beego.Router("/cancel", &controllers.BarController{}, "Post:Cancel")
+	beego.Router("/confirm", &controllers.BarController{}, "Post:Confirm")
+	beego.Router("/seat", &controllers.BarController{}, "Post:Seat")
+	beego.Router("/complete", &controllers.BarController{}, "Post:Complete")
+	beego.Router("/deposit/pay", &controllers.BarController{}, "Post:DepositPay")
+	beego.Router("/deposit/report", &controllers.BarController{}, "Get:DepositReport")
+	beego.Router("/spend", &controllers.BarController{}, "Post:Spend")
+	beego.Router("/batch", &controllers.BarController{}, "Post:Batch")
+	beego.Router("/log", &controllers.BarController{}, "Get:Log")
+	beego.Router("/layout/delete", &controllers.BarController{}, "Post:LayoutDelete")
+	beego.Router("/site/save", &controllers.BarController{}, "Post:SiteSave")
+	beego.Router("/suggest", &controllers.BarController{}, "Get:Suggest")
+	beego.Router("/group", &controllers.BarController{}, "Get:Group")
+
+	beego.Router("/customer/lookup", &controllers.CustomerController{}, "Get:Lookup")
+	beego.Router("/customer/list", &controllers.CustomerController{}, "Get:List")
+	beego.Router("/customer/save", &controllers.CustomerController{}, "Post:Save")
+
+	beego.Router("/drink/list", &controllers.DrinkController{}, "Get:List")
+	beego.Router("/drink/save", &controllers.DrinkController{}, "Post:Save")
+	beego.Router("/drink/delete", &controllers.DrinkController{}, "Post:Delete")
+	beego.Router("/preorder/list", &controllers.DrinkController{}, "Get:PreOrderList")
+	beego.Router("/preorder/add", &controllers.DrinkController{}, "Post:PreOrderAdd")
+	beego.Router("/preorder/cancel", &controllers.DrinkController{}, "Post:PreOrderCancel")
+	beego.Router("/report/nightly", &controllers.DrinkController{}, "Get:Nightly")
+
+	beego.Router("/promotion/list", &controllers.PromotionController{}, "Get:List")
+	beego.Router("/promotion/save", &controllers.PromotionController{}, "Post:Save")
+	beego.Router("/promotion/delete", &controllers.PromotionController{}, "Post:Delete")
+
+	beego.Router("/appeal/list", &controllers.AppealController{}, "Get:List")
+	beego.Router("/appeal/create", &controllers.AppealController{}, "Post:Create")
+	beego.Router("/appeal/review", &controllers.AppealController{}, "Post:Review")
+
+	beego.Router("/rank", &controllers.RankController{}, "Get:List")
+
+	beego.Router("/team", &controllers.TeamController{}, "Get:Index")
+	beego.Router("/team/create", &controllers.TeamController{}, "Post:Create")
+	beego.Router("/team/invite", &controllers.TeamController{}, "Post:Invite")
+	beego.Router("/team/join", &controllers.TeamController{}, "Post:Join")
+	beego.Router("/team/remove", &controllers.TeamController{}, "Post:Remove")
+	beego.Router("/team/dissolve", &controllers.TeamController{}, "Post:Dissolve")
+	beego.Router("/team/desks", &controllers.TeamController{}, "Get:Desks")
+	beego.Router("/team/assign", &controllers.TeamController{}, "Post:Assign")
+
+	beego.Router("/totp/enroll", &controllers.TotpController{}, "Post:Enroll")
+	beego.Router("/totp/activate", &controllers.TotpController{}, "Post:Activate")
+	beego.Router("/totp/recovery", &controllers.TotpController{}, "Post:RecoveryCodes")
+	beego.Router("/totp/disable", &controllers.TotpController{}, "Post:Disable")
+
+	beego.Router("/waitlist/list", &controllers.WaitlistController{}, "Get:List")
+	beego.Router("/waitlist/add", &controllers.WaitlistController{}, "Post:Add")
+	beego.Router("/waitlist/offer", &controllers.WaitlistController{}, "Post:Offer")
+	beego.Router("/waitlist/convert", &controllers.WaitlistController{}, "Post:Convert")
+	beego.Router("/waitlist/leave", &controllers.WaitlistController{}, "Post:Leave")
+
+	beego.Router("/staff/list", &controllers.StaffController{}, "Get:List")
+	beego.Router("/staff/save", &controllers.StaffController{}, "Post:Save")
+	beego.Router("/staff/delete", &controllers.StaffController{}, "Post:Delete")
+	beego.Router("/staff/revoke", &controllers.StaffController{}, "Post:Revoke")
+
+	beego.Router("/setting", &controllers.SettingController{}, "Get:Get")
+	beego.Router("/setting/save", &controllers.SettingController{}, "Post:Save")
+
+	beego.Router("/permission/matrix", &controllers.PermissionController{}, "Get:Matrix")
+	beego.Router("/permission/save", &controllers.PermissionController{}, "Post:Save")
+
+	beego.Router("/partner/list", &controllers.PartnerController{}, "Get:List")
+	beego.Router("/partner/create", &controllers.PartnerController{}, "Post:Create")
+	beego.Router("/partner/status", &controllers.PartnerController{}, "Post:Status")
+	beego.Router(filters.PartnerApiPrefix+"desks", &controllers.PartnerApiController{}, "Get,Post:Desks")
+	beego.Router(filters.PartnerApiPrefix+"order", &controllers.PartnerApiController{}, "Post:Order")
+	beego.Router(filters.PartnerApiPrefix+"cancel", &controllers.PartnerApiController{}, "Post:Cancel")
+
+	beego.Router("/device/pair/code", &controllers.DeviceController{}, "Post:PairCode")
+	beego.Router("/device/pair", &controllers.DeviceController{}, "Post:Pair")
+	beego.Router("/device/list", &controllers.DeviceController{}, "Get:List")
+	beego.Router("/device/revoke", &controllers.DeviceController{}, "Post:Revoke")
+	beego.Router("/device/pin", &controllers.DeviceController{}, "Post:Pin")
+
+	beego.Router("/hold", &controllers.HoldController{}, "Post:Hold")
+	beego.Router("/hold/release", &controllers.HoldController{}, "Post:Release")
+	beego.Router("/hold/list", &controllers.HoldController{}, "Get:List")
+	beego.Router("/alarm", &controllers.AlarmController{}, "Get:Poll")
 }
@@ -0,0 +1,30 @@
+package routers
+
+import (
+	"BossBar/enums"
+	"BossBar/filters"
+	"testing"
+
+	"github.com/astaxie/beego"
+)
+
+// 新增路由须登记权限, 否则PermissionFilter不会拦截
+func TestRoutePermissionDeclared(t *testing.T) {
+	data := beego.PrintTree()["Data"].(beego.M)
+	for method, v := range data {
+		for _, route := range *v.(*[][]string) {
+			if !filters.RoutePermissionDeclared(route[0]) {
+				t.Errorf("%s %s 未登记在PermissionFilter的权限表中", method, route[0])
+			}
+		}
+	}
+}
+
+// 路径变体与原路径需要同样的权限
+func TestRoutePermissionCleanPath(t *testing.T) {
+	for _, p := range []string{"/batch", "/batch/", "//batch", "/batch/./", "/log/../batch"} {
+		if perm := filters.RoutePermission(p); perm != enums.PermBatchClear {
+			t.Errorf("%s 需要权限 %q, 实际为 %q", p, enums.PermBatchClear, perm)
+		}
+	}
+}
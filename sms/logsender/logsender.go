@@ -0,0 +1,33 @@
+// Package logsender for sms provider
+//
+// only write sms content to log, used in dev environment.
+package logsender
+
+import (
+	"BossBar/sms"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Sender is log sms adapter.
+type Sender struct{}
+
+// NewLogSender create new log sms sender.
+func NewLogSender() sms.Sender {
+	return &Sender{}
+}
+
+// Send write sms content to log.
+func (s *Sender) Send(phone, content string) error {
+	log.Infof("[sms] phone:%s, content:%s", phone, content)
+	return nil
+}
+
+// Init nothing to init.
+func (s *Sender) Init(config string) error {
+	return nil
+}
+
+func init() {
+	sms.Register("log", NewLogSender)
+}
@@ -0,0 +1,59 @@
+// Package sms provide a Sender interface and some implement engine
+// Usage:
+//
+// import(
+//
+//	"BossBar/sms"
+//	_ "BossBar/sms/logsender"
+//
+// )
+//
+//	s, err := sms.NewSender("log", `{}`)
+//	s.Send("13800138000", "您的验证码为123456")
+package sms
+
+import (
+	"fmt"
+)
+
+// Sender interface contains all behaviors for sms adapter.
+type Sender interface {
+	// send content to phone.
+	Send(phone, content string) error
+	// init adapter with config string settings.
+	Init(config string) error
+}
+
+// Instance is a function create a new Sender Instance
+type Instance func() Sender
+
+var adapters = make(map[string]Instance)
+
+// Register makes a sms adapter available by the adapter name.
+// If Register is called twice with the same name or if driver is nil,
+// it panics.
+func Register(name string, adapter Instance) {
+	if adapter == nil {
+		panic("sms: Register adapter is nil")
+	}
+	if _, ok := adapters[name]; ok {
+		panic("sms: Register called twice for adapter " + name)
+	}
+	adapters[name] = adapter
+}
+
+// NewSender Create a new sms sender by adapter name and config string.
+// config need to be correct JSON as string.
+func NewSender(adapterName, config string) (adapter Sender, err error) {
+	instanceFunc, ok := adapters[adapterName]
+	if !ok {
+		err = fmt.Errorf("sms: unknown adapter name %q (forgot to import?)", adapterName)
+		return
+	}
+	adapter = instanceFunc()
+	err = adapter.Init(config)
+	if err != nil {
+		adapter = nil
+	}
+	return
+}
@@ -0,0 +1,45 @@
+package sysinit
+
+import (
+	_ "BossBar/models"
+
+	"github.com/astaxie/beego"
+	"github.com/astaxie/beego/orm"
+	_ "github.com/go-sql-driver/mysql"
+	log "github.com/sirupsen/logrus"
+)
+
+// InitDatabase 初始化数据连接
+func InitDatabase() {
+	//读取配置方式一
+	dbType := beego.AppConfig.String("db_type")
+	//连接名称
+	dbAlias := beego.AppConfig.String(dbType + "::db_alias")
+	//数据库名称
+	dbName := beego.AppConfig.String(dbType + "::db_name")
+	//数据库连接用户名
+	dbUser := beego.AppConfig.String(dbType + "::db_user")
+	//数据库连接用户名
+	dbPwd := beego.AppConfig.String(dbType + "::db_pwd")
+	//数据库IP（域名）
+	dbHost := beego.AppConfig.String(dbType + "::db_host")
+	//数据库端口
+	dbPort := beego.AppConfig.String(dbType + "::db_port")
+	switch dbType {
+	case "mysql":
+		if dbUser == "" || dbPwd == "" {
+			log.Fatalf("%s::db_user and %s::db_pwd are required", dbType, dbType)
+		}
+		dbCharset := beego.AppConfig.String(dbType + "::db_charset")
+		orm.RegisterDataBase(dbAlias, dbType, dbUser+":"+dbPwd+"@tcp("+dbHost+":"+dbPort+")/"+dbName+"?charset="+dbCharset+"&loc=Local", 30)
+	default:
+		log.Fatalf("unsupported db_type: %s", dbType)
+	}
+	//如果是开发模式，则显示命令信息
+	isDev := beego.AppConfig.String("runmode") == "dev"
+	//自动建表
+	orm.RunSyncdb("default", false, isDev)
+	if isDev {
+		orm.Debug = isDev
+	}
+}
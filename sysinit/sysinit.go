@@ -1,6 +1,7 @@
 package sysinit
 
 import (
+	"BossBar/models"
 	"BossBar/utils"
 	"math/rand"
 	"time"
@@ -14,8 +15,26 @@ func init() {
 
 	utils.InitLogs()
 
+	//初始化数据库
+	InitDatabase()
+
 	//初始化缓存
 	utils.InitCache()
 
+	//短信
+	utils.InitSms()
+
+	//jwt密钥环
+	utils.InitJwtKeys()
+
+	//xsrf签名密钥
+	utils.InitXsrf()
+
+	//过期事件监听
+	utils.InitListener()
+
+	//爽约自动释放
+	models.InitNoShowJob()
+
 	log.Info("Initialized is done~")
 }
@@ -118,6 +118,18 @@ func DelCache(key string) error {
 	}
 }
 
+// RenameCache 重命名key, newKey已存在时被原子替换
+func RenameCache(oldKey, newKey string) error {
+	if cc == nil {
+		return errors.New("cc is nil")
+	}
+	err := cc.Rename(oldKey, newKey)
+	if err != nil {
+		log.Errorf("RenameCache err, key:%s, err:%s", oldKey, err.Error())
+	}
+	return err
+}
+
 func GetPureCache(key string) (result string, err error) {
 	if cc == nil {
 		return "", errors.New("cc is nil")
@@ -2,21 +2,36 @@ package utils
 
 import (
 	"BossBar/conf"
-	"fmt"
+	"errors"
+	"github.com/astaxie/beego"
 	"github.com/dgrijalva/jwt-go"
+	"strconv"
 	"time"
 )
 
-/// 指定加密密钥
-var jwtSecret = []byte(conf.SecretKey)
+var (
+	ErrTokenRevoked = errors.New("token已失效")
+	ErrTokenReused  = errors.New("refresh token重复使用, 已注销该登录")
+)
 
 //Claim是一些实体（通常指的用户）的状态和额外的元数据
 type Claims struct {
-	Id       int `json:"data"`
-	AccessId int `json:"data1"`
+	Id       int    `json:"data"`
+	AccessId int    `json:"data1"`
+	Family   string `json:"fam,omitempty"` //同一次登录轮换出的token属于同一family
+	Refresh  bool   `json:"rft,omitempty"` //是否refresh token
+	Device   int    `json:"dev,omitempty"` //PIN登录时所在的平板
 	jwt.StandardClaims
 }
 
+// TokenPair 短期访问token和长期refresh token
+type TokenPair struct {
+	AccessToken      string `json:"token"`
+	AccessExpiresAt  int64  `json:"expires_at"`
+	RefreshToken     string `json:"refresh_token"`
+	RefreshExpiresAt int64  `json:"refresh_expires_at"`
+}
+
 // 根据产生token
 func GenerateToken(merchantId int, expiresAt int64) (string, error) {
 	//设置token有效时间
@@ -24,6 +39,7 @@ func GenerateToken(merchantId int, expiresAt int64) (string, error) {
 	claims := Claims{
 		Id: merchantId,
 		StandardClaims: jwt.StandardClaims{
+			Id: SecureRandomString(24),
 			// 过期时间
 			ExpiresAt: expiresAt,
 			// 指定token发行人
@@ -32,19 +48,25 @@ func GenerateToken(merchantId int, expiresAt int64) (string, error) {
 		},
 	}
 
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	//该方法内部生成签名字符串，再用于获取完整、已签名的token
-	token, err := tokenClaims.SignedString(jwtSecret)
-	return token, err
+	//使用密钥环当前的签名密钥生成签名字符串，头部带kid
+	return signClaims(claims)
 }
 
 // 产生访问token
 func GenerateAccessToken(curMerchantId, accessId int, expiresAt int64) (string, error) {
+	return generateFamilyToken(curMerchantId, accessId, 0, "", false, SecureRandomString(24), expiresAt)
+}
+
+func generateFamilyToken(curMerchantId, accessId, deviceId int, family string, refresh bool, jti string, expiresAt int64) (string, error) {
 	//设置token有效时间
 	claims := Claims{
 		Id:       curMerchantId,
 		AccessId: accessId,
+		Family:   family,
+		Refresh:  refresh,
+		Device:   deviceId,
 		StandardClaims: jwt.StandardClaims{
+			Id: jti,
 			// 过期时间
 			ExpiresAt: expiresAt,
 			// 指定token发行人
@@ -52,30 +74,216 @@ func GenerateAccessToken(curMerchantId, accessId int, expiresAt int64) (string,
 		},
 	}
 
-	tokenClaims := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	//该方法内部生成签名字符串，再用于获取完整、已签名的token
-	token, err := tokenClaims.SignedString(jwtSecret)
-	return token, err
+	//使用密钥环当前的签名密钥生成签名字符串，头部带kid
+	return signClaims(claims)
 }
 
 // 根据传入的token值获取到Claims对象信息，（进而获取其中的用户名和密码）
 func ParseToken(token string) (*Claims, error) {
 
 	//用于解析鉴权的声明，方法内部主要是具体的解码和校验的过程，最终返回*Token
-	tokenClaims, err := jwt.ParseWithClaims(token, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
-		}
-		return jwtSecret, nil
-	})
+	//按kid从密钥环中选择验证密钥
+	tokenClaims, err := jwt.ParseWithClaims(token, &Claims{}, jwtKeyFunc)
 
 	if tokenClaims != nil {
 		// 从tokenClaims中获取到Claims对象，并使用断言，将该对象转换为我们自己定义的Claims
 		// 要传入指针，项目中结构体都是用指针传递，节省空间。
 		if claims, ok := tokenClaims.Claims.(*Claims); ok && tokenClaims.Valid {
+			if IsTokenRevoked(claims) {
+				return nil, ErrTokenRevoked
+			}
 			return claims, nil
 		}
 	}
 	return nil, err
 
 }
+
+func accessTokenTTL() time.Duration {
+	minutes, err := beego.AppConfig.Int("jwt::access_expire_minutes")
+	if err != nil || minutes <= 0 {
+		minutes = 30
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func refreshTokenTTL() time.Duration {
+	hours, err := beego.AppConfig.Int("jwt::refresh_expire_hours")
+	if err != nil || hours <= 0 {
+		hours = 720
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// family当前有效的refresh token jti
+func tokenFamilyKey(family string) string {
+	return "jwt_family:" + family
+}
+
+// 员工名下的全部family, 用于注销某员工所有登录
+func staffFamiliesKey(staffId int) string {
+	return "jwt_families:" + strconv.Itoa(staffId)
+}
+
+func tokenBlacklistKey(jti string) string {
+	return "jwt_blacklist:" + jti
+}
+
+// 已轮换的refresh token jti, 宽限期内缓存轮换出的新token对
+func tokenRotatedKey(jti string) string {
+	return "jwt_rotated:" + jti
+}
+
+// 同一refresh token的并发刷新宽限期(秒), 如平板轮询和页面请求同时刷新
+func refreshGraceSeconds() int {
+	seconds, err := beego.AppConfig.Int("jwt::refresh_grace_seconds")
+	if err != nil || seconds <= 0 {
+		seconds = 30
+	}
+	return seconds
+}
+
+func familyRevokedKey(family string) string {
+	return "jwt_family_revoked:" + family
+}
+
+func deviceRevokedKey(deviceId int) string {
+	return "jwt_device_revoked:" + strconv.Itoa(deviceId)
+}
+
+// GenerateTokenPair 登录时签发新的token family
+func GenerateTokenPair(merchantId, staffId int) (*TokenPair, error) {
+	return GenerateDeviceTokenPair(merchantId, staffId, 0)
+}
+
+// GenerateDeviceTokenPair 在已配对平板上登录, token绑定设备, 吊销设备时一并失效
+func GenerateDeviceTokenPair(merchantId, staffId, deviceId int) (*TokenPair, error) {
+	family := SecureRandomString(24)
+	pair, err := issueTokenPair(merchantId, staffId, deviceId, family)
+	if err != nil {
+		return nil, err
+	}
+	SAddCache(staffFamiliesKey(staffId), family)
+	ExpireCache(staffFamiliesKey(staffId), int64(refreshTokenTTL()/time.Second))
+	return pair, nil
+}
+
+func issueTokenPair(merchantId, staffId, deviceId int, family string) (*TokenPair, error) {
+	now := time.Now()
+	pair := &TokenPair{
+		AccessExpiresAt:  now.Add(accessTokenTTL()).Unix(),
+		RefreshExpiresAt: now.Add(refreshTokenTTL()).Unix(),
+	}
+	accessJti, refreshJti := SecureRandomString(24), SecureRandomString(24)
+	var err error
+	if pair.AccessToken, err = generateFamilyToken(merchantId, staffId, deviceId, family, false, accessJti, pair.AccessExpiresAt); err != nil {
+		return nil, err
+	}
+	if pair.RefreshToken, err = generateFamilyToken(merchantId, staffId, deviceId, family, true, refreshJti, pair.RefreshExpiresAt); err != nil {
+		return nil, err
+	}
+	key := tokenFamilyKey(family)
+	if _, err = HSetCache(key, "jti", refreshJti); err != nil {
+		return nil, err
+	}
+	ExpireCache(key, int64(refreshTokenTTL()/time.Second))
+	return pair, nil
+}
+
+// RefreshTokenPair 使用refresh token换取新的token对, 旧refresh token随即作废
+// 同一refresh token只轮换一次, 宽限期内的并发请求拿到同一对新token
+// 宽限期后已作废的refresh token再次使用视为被盗, 注销整个family
+func RefreshTokenPair(refreshToken string) (*TokenPair, error) {
+	claims, err := ParseToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if !claims.Refresh || claims.Family == "" {
+		return nil, ErrTokenRevoked
+	}
+	jti := claims.StandardClaims.Id
+	grace := refreshGraceSeconds()
+	// 抢到锁的请求负责比较并轮换, 其余请求等待轮换结果
+	if SetNxExCache(tokenRotatedKey(jti)+":lock", "1", grace) != nil {
+		return rotatedTokenPair(jti)
+	}
+	cur, err := HGetCache(tokenFamilyKey(claims.Family), "jti")
+	if err != nil {
+		return nil, ErrTokenRevoked
+	}
+	if cur != jti {
+		RevokeFamily(claims.Family)
+		return nil, ErrTokenReused
+	}
+	pair, err := issueTokenPair(claims.Id, claims.AccessId, claims.Device, claims.Family)
+	if err != nil {
+		DelCache(tokenRotatedKey(jti) + ":lock")
+		return nil, err
+	}
+	SetCache(tokenRotatedKey(jti), pair, grace)
+	return pair, nil
+}
+
+// 等待并发请求轮换出的新token对
+func rotatedTokenPair(jti string) (*TokenPair, error) {
+	var pair TokenPair
+	for i := 0; i < 20; i++ {
+		if GetCache(tokenRotatedKey(jti), &pair) == nil {
+			return &pair, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil, ErrTokenRevoked
+}
+
+// RevokeToken 将单个token加入黑名单直至其过期
+func RevokeToken(claims *Claims) {
+	ttl := claims.ExpiresAt - time.Now().Unix()
+	if claims.StandardClaims.Id == "" || ttl <= 0 {
+		return
+	}
+	SetNxExCache(tokenBlacklistKey(claims.StandardClaims.Id), "1", int(ttl))
+}
+
+// RevokeFamily 注销一次登录签发的全部token
+func RevokeFamily(family string) {
+	if family == "" {
+		return
+	}
+	SetNxExCache(familyRevokedKey(family), "1", int(refreshTokenTTL()/time.Second))
+	DelCache(tokenFamilyKey(family))
+}
+
+// RevokeStaffTokens 注销员工的全部登录, 如平板丢失
+func RevokeStaffTokens(staffId int) {
+	families, _ := SMembersCache(staffFamiliesKey(staffId))
+	for _, family := range families {
+		RevokeFamily(family)
+	}
+	DelCache(staffFamiliesKey(staffId))
+}
+
+// RevokeDeviceTokens 吊销平板后, 该平板上签发的token全部失效
+func RevokeDeviceTokens(deviceId int) {
+	SetNxExCache(deviceRevokedKey(deviceId), "1", int(refreshTokenTTL()/time.Second))
+}
+
+// IsTokenRevoked 检查jti黑名单及family、设备是否已注销
+func IsTokenRevoked(claims *Claims) bool {
+	if claims.StandardClaims.Id != "" {
+		if exists, _ := ExistsCache(tokenBlacklistKey(claims.StandardClaims.Id)); exists {
+			return true
+		}
+	}
+	if claims.Family != "" {
+		if exists, _ := ExistsCache(familyRevokedKey(claims.Family)); exists {
+			return true
+		}
+	}
+	if claims.Device != 0 {
+		if exists, _ := ExistsCache(deviceRevokedKey(claims.Device)); exists {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,192 @@
+package utils
+
+import (
+	"BossBar/cache"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// 内存缓存, 只实现token签发和轮换用到的命令, 不处理过期
+type memCache struct {
+	cache.Cache
+	mu     sync.Mutex
+	values map[string][]byte
+	hashes map[string]map[string]string
+	sets   map[string]map[string]bool
+}
+
+func newMemCache() *memCache {
+	return &memCache{values: map[string][]byte{}, hashes: map[string]map[string]string{}, sets: map[string]map[string]bool{}}
+}
+
+func (c *memCache) Get(key string) interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v, ok := c.values[key]; ok {
+		return v
+	}
+	return nil
+}
+
+func (c *memCache) Set(key string, value interface{}, seconds, milliseconds int, mustExists, mustNotExists bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.values[key]; ok == mustNotExists && (mustExists || mustNotExists) {
+		return errors.New("redigo: nil returned")
+	}
+	c.values[key] = []byte(fmt.Sprint(value))
+	return nil
+}
+
+func (c *memCache) Put(key string, val interface{}, timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = val.([]byte)
+	return nil
+}
+
+func (c *memCache) Exists(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.values[key]
+	_, hok := c.hashes[key]
+	return ok || hok, nil
+}
+
+func (c *memCache) Expire(key string, time int64) (bool, error) {
+	return true, nil
+}
+
+func (c *memCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	delete(c.hashes, key)
+	delete(c.sets, key)
+	return nil
+}
+
+func (c *memCache) HSet(key, field, value string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hashes[key] == nil {
+		c.hashes[key] = map[string]string{}
+	}
+	c.hashes[key][field] = value
+	return true, nil
+}
+
+func (c *memCache) HGet(key, field string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.hashes[key][field]
+	if !ok {
+		return "", errors.New("redigo: nil returned")
+	}
+	return v, nil
+}
+
+func (c *memCache) SAdd(key string, members ...interface{}) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sets[key] == nil {
+		c.sets[key] = map[string]bool{}
+	}
+	for _, v := range members {
+		c.sets[key][fmt.Sprint(v)] = true
+	}
+	return len(members), nil
+}
+
+func (c *memCache) SMembers(key string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := make([]string, 0, len(c.sets[key]))
+	for v := range c.sets[key] {
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// 使用内存缓存和测试密钥, 测试结束后还原
+func setupTokenTest(t *testing.T) *memCache {
+	key, err := buildJwtKey(jwtKeyConfig{Kid: "test", Alg: jwt.SigningMethodHS256.Alg(), Secret: strings.Repeat("k", defaultSecretMinLen)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := newMemCache()
+	oldCache, oldKeys := cc, jwtKeys
+	cc, jwtKeys = mem, []*JwtKey{key}
+	t.Cleanup(func() {
+		cc, jwtKeys = oldCache, oldKeys
+	})
+	return mem
+}
+
+// refresh token换取新的token对, 新token可继续刷新, access token不能用来刷新
+func TestRefreshTokenPairRotation(t *testing.T) {
+	setupTokenTest(t)
+	pair, err := GenerateTokenPair(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RefreshTokenPair(pair.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("access token刷新 err = %v, 期望 %v", err, ErrTokenRevoked)
+	}
+	next, err := RefreshTokenPair(pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.RefreshToken == pair.RefreshToken || next.AccessToken == pair.AccessToken {
+		t.Error("刷新后应签发新的token对")
+	}
+	claims, err := ParseToken(next.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Id != 1 || claims.AccessId != 2 || claims.Refresh {
+		t.Errorf("新access token claims = %+v", claims)
+	}
+	if _, err = RefreshTokenPair(next.RefreshToken); err != nil {
+		t.Errorf("新refresh token刷新失败: %v", err)
+	}
+}
+
+// 宽限期内重复刷新拿到同一对新token, 宽限期后重复使用注销整个family
+func TestRefreshTokenPairReuse(t *testing.T) {
+	mem := setupTokenTest(t)
+	pair, err := GenerateTokenPair(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := RefreshTokenPair(pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := RefreshTokenPair(pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("宽限期内重复刷新失败: %v", err)
+	}
+	if again.RefreshToken != next.RefreshToken || again.AccessToken != next.AccessToken {
+		t.Error("宽限期内重复刷新应拿到同一对新token")
+	}
+
+	// 宽限期过后锁和轮换结果都已过期
+	claims, _ := ParseToken(pair.RefreshToken)
+	mem.Delete(tokenRotatedKey(claims.StandardClaims.Id) + ":lock")
+	mem.Delete(tokenRotatedKey(claims.StandardClaims.Id))
+	if _, err = RefreshTokenPair(pair.RefreshToken); err != ErrTokenReused {
+		t.Fatalf("宽限期后重复使用 err = %v, 期望 %v", err, ErrTokenReused)
+	}
+	for _, token := range []string{next.AccessToken, next.RefreshToken} {
+		if _, err = ParseToken(token); err != ErrTokenRevoked {
+			t.Errorf("family注销后 ParseToken err = %v, 期望 %v", err, ErrTokenRevoked)
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package utils
+
+import (
+	"BossBar/conf"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/astaxie/beego"
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+)
+
+// jwt密钥环
+// 每个token头部带kid, 验证时按kid选择密钥; 签名使用sign_from已到且最晚的密钥, 以此实现按计划轮换
+// keyring_file 格式:
+// [
+//   {"kid":"hs-2026-10","alg":"HS256","secret":"xxx","sign_from":"2026-10-01 00:00:00","verify_until":"2026-12-01 00:00:00"},
+//   {"kid":"rs-2026-11","alg":"RS256","private_key":"conf/keys/rs.pem","public_key":"conf/keys/rs.pub.pem","sign_from":"2026-11-01 00:00:00"},
+//   {"kid":"ed-2026-12","alg":"EdDSA","private_key":"conf/keys/ed.pem","public_key":"conf/keys/ed.pub.pem","sign_from":"2026-12-01 00:00:00"}
+// ]
+// 只配置public_key的密钥仅用于验证; RS256/EdDSA公钥通过JWKS公开, 其他服务无需持有密钥即可验证
+
+const keyTimeLayout = "2006-01-02 15:04:05"
+
+type jwtKeyConfig struct {
+	Kid         string `json:"kid"`
+	Alg         string `json:"alg"`
+	Secret      string `json:"secret"`
+	PrivateKey  string `json:"private_key"`
+	PublicKey   string `json:"public_key"`
+	SignFrom    string `json:"sign_from"`
+	VerifyUntil string `json:"verify_until"`
+}
+
+// JwtKey 密钥环中的一把密钥
+type JwtKey struct {
+	Kid         string
+	Method      jwt.SigningMethod
+	SignKey     interface{} //为nil时仅用于验证
+	VerifyKey   interface{}
+	SignFrom    time.Time
+	VerifyUntil time.Time //零值表示长期有效
+}
+
+var (
+	jwtKeys   []*JwtKey
+	jwtKeysMu sync.RWMutex
+)
+
+func init() {
+	jwt.RegisterSigningMethod(signingMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return signingMethodEdDSA
+	})
+	if key, err := defaultJwtKey(); err == nil && key != nil {
+		jwtKeys = []*JwtKey{key}
+	}
+}
+
+// 默认HS256密钥的最短长度
+const defaultSecretMinLen = 32
+
+// 默认HS256密钥的kid, 未带kid的旧token也用它验证
+func defaultKeyKid() string {
+	return beego.AppConfig.DefaultString("jwt::secret_kid", "default")
+}
+
+// 未配置密钥环或token未带kid时使用的默认HS256密钥, 与密钥环中的密钥一样可设置verify_until退役
+// secret_key为空时不使用默认密钥
+func defaultJwtKey() (*JwtKey, error) {
+	if conf.SecretKey == "" {
+		return nil, nil
+	}
+	if len(conf.SecretKey) < defaultSecretMinLen {
+		return nil, fmt.Errorf("secret_key must be at least %d characters", defaultSecretMinLen)
+	}
+	return buildJwtKey(jwtKeyConfig{
+		Kid:         defaultKeyKid(),
+		Alg:         jwt.SigningMethodHS256.Alg(),
+		Secret:      conf.SecretKey,
+		VerifyUntil: beego.AppConfig.String("jwt::secret_verify_until"),
+	})
+}
+
+// InitJwtKeys 加载密钥环并定时重新加载, 新增密钥无需重启
+// 首次加载失败或没有可用的签名密钥时拒绝启动
+func InitJwtKeys() {
+	if err := ReloadJwtKeys(); err != nil {
+		log.Fatalf("[jwt] load keyring failed, err:%s", err.Error())
+	}
+	if currentSigningKey() == nil {
+		log.Fatal("[jwt] no signing key, set jwt::secret_key or jwt::keyring_file")
+	}
+	minutes, err := beego.AppConfig.Int("jwt::keyring_reload_minutes")
+	if err != nil || minutes <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(time.Duration(minutes) * time.Minute) {
+			if err := ReloadJwtKeys(); err != nil {
+				log.Errorf("[jwt] reload keyring failed, err:%s", err.Error())
+			}
+		}
+	}()
+}
+
+// ReloadJwtKeys 重新读取密钥环文件, 默认密钥在退役前保留用于验证旧token
+func ReloadJwtKeys() error {
+	keys := make([]*JwtKey, 0)
+	key, err := defaultJwtKey()
+	if err != nil {
+		return fmt.Errorf("kid %s: %s", defaultKeyKid(), err.Error())
+	}
+	if key != nil {
+		keys = append(keys, key)
+	}
+	file := beego.AppConfig.String("jwt::keyring_file")
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var configs []jwtKeyConfig
+		if err = json.Unmarshal(data, &configs); err != nil {
+			return err
+		}
+		for _, cfg := range configs {
+			key, err := parseJwtKey(cfg)
+			if err != nil {
+				return fmt.Errorf("kid %s: %s", cfg.Kid, err.Error())
+			}
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].SignFrom.Before(keys[j].SignFrom)
+	})
+
+	jwtKeysMu.Lock()
+	jwtKeys = keys
+	jwtKeysMu.Unlock()
+	return nil
+}
+
+func parseJwtKey(cfg jwtKeyConfig) (*JwtKey, error) {
+	if cfg.Kid == "" || cfg.Kid == defaultKeyKid() {
+		return nil, errors.New("kid is empty or reserved")
+	}
+	return buildJwtKey(cfg)
+}
+
+func buildJwtKey(cfg jwtKeyConfig) (*JwtKey, error) {
+	key := &JwtKey{Kid: cfg.Kid, Method: jwt.GetSigningMethod(cfg.Alg)}
+	if key.Method == nil {
+		return nil, errors.New("unsupported alg " + cfg.Alg)
+	}
+	var err error
+	if cfg.SignFrom != "" {
+		if key.SignFrom, err = time.ParseInLocation(keyTimeLayout, cfg.SignFrom, time.Local); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.VerifyUntil != "" {
+		if key.VerifyUntil, err = time.ParseInLocation(keyTimeLayout, cfg.VerifyUntil, time.Local); err != nil {
+			return nil, err
+		}
+	}
+
+	switch key.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if cfg.Secret == "" {
+			return nil, errors.New("secret is empty")
+		}
+		key.SignKey, key.VerifyKey = []byte(cfg.Secret), []byte(cfg.Secret)
+	case *jwt.SigningMethodRSA:
+		if cfg.PrivateKey != "" {
+			data, err := ioutil.ReadFile(cfg.PrivateKey)
+			if err != nil {
+				return nil, err
+			}
+			if key.SignKey, err = jwt.ParseRSAPrivateKeyFromPEM(data); err != nil {
+				return nil, err
+			}
+		}
+		data, err := ioutil.ReadFile(cfg.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		if key.VerifyKey, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+			return nil, err
+		}
+	case *signingMethodEd25519:
+		if cfg.PrivateKey != "" {
+			if key.SignKey, err = parseEd25519Key(cfg.PrivateKey, true); err != nil {
+				return nil, err
+			}
+		}
+		if key.VerifyKey, err = parseEd25519Key(cfg.PublicKey, false); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unsupported alg " + cfg.Alg)
+	}
+	return key, nil
+}
+
+func parseEd25519Key(file string, private bool) (interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid pem " + file)
+	}
+	var key interface{}
+	if private {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	} else {
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case ed25519.PrivateKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, errors.New("not an ed25519 key " + file)
+}
+
+// 当前签名密钥: sign_from已到且最晚的可签名密钥
+func currentSigningKey() *JwtKey {
+	now := time.Now()
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	var cur *JwtKey
+	for _, key := range jwtKeys {
+		if key.SignKey == nil || key.SignFrom.After(now) {
+			continue
+		}
+		if !key.VerifyUntil.IsZero() && key.VerifyUntil.Before(now) {
+			continue
+		}
+		cur = key
+	}
+	return cur
+}
+
+// 按kid查找验证密钥, 未带kid的旧token使用默认密钥
+func verifyingKey(kid string) *JwtKey {
+	if kid == "" {
+		kid = defaultKeyKid()
+	}
+	now := time.Now()
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	for _, key := range jwtKeys {
+		if key.Kid != kid {
+			continue
+		}
+		if !key.VerifyUntil.IsZero() && key.VerifyUntil.Before(now) {
+			return nil
+		}
+		return key
+	}
+	return nil
+}
+
+// 用当前签名密钥签发token
+func signClaims(claims jwt.Claims) (string, error) {
+	key := currentSigningKey()
+	if key == nil {
+		return "", errors.New("no jwt signing key available")
+	}
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.Kid
+	return token.SignedString(key.SignKey)
+}
+
+// 解析token时的密钥选择, 算法须与kid对应的密钥一致
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key := verifyingKey(kid)
+	if key == nil {
+		return nil, fmt.Errorf("Unknown kid: %v", token.Header["kid"])
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.VerifyKey, nil
+}
+
+// JWKS 公开的验证公钥(RFC 7517), HMAC密钥不公开
+func JWKS() map[string]interface{} {
+	jwtKeysMu.RLock()
+	defer jwtKeysMu.RUnlock()
+	keys := make([]map[string]string, 0)
+	for _, key := range jwtKeys {
+		switch pub := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": key.Method.Alg(),
+				"kid": key.Kid,
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"use": "sig",
+				"alg": key.Method.Alg(),
+				"kid": key.Kid,
+				"crv": "Ed25519",
+				"x":   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// jwt-go v3 未内置EdDSA, 按RFC 8037实现Ed25519签名
+type signingMethodEd25519 struct{}
+
+var signingMethodEdDSA = &signingMethodEd25519{}
+
+func (m *signingMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return "", jwt.ErrInvalidKeyType
+	}
+	return jwt.EncodeSegment(ed25519.Sign(priv, []byte(signingString))), nil
+}
+
+func (m *signingMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return jwt.ErrInvalidKeyType
+	}
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/astaxie/beego"
+)
+
+// 轮询模式下记录待过期key的有序集合, score为过期时间戳
+const expireWatchKey = "expire_watch"
+
+// ExpireHandler 过期事件处理函数, key不含缓存前缀
+type ExpireHandler func(key string)
+
+var (
+	expireHandlers = make(map[string]ExpireHandler)
+	expireMu       sync.RWMutex
+	listenerStop   chan struct{}
+)
+
+// RegisterExpireHandler
+// 按key前缀注册过期处理函数, 如释放订台锁定、响铃提醒
+func RegisterExpireHandler(prefix string, handler ExpireHandler) {
+	if handler == nil {
+		panic("listener: RegisterExpireHandler handler is nil")
+	}
+	expireMu.Lock()
+	defer expireMu.Unlock()
+	if _, ok := expireHandlers[prefix]; ok {
+		panic("listener: RegisterExpireHandler called twice for prefix " + prefix)
+	}
+	expireHandlers[prefix] = handler
+}
+
+// SetExpireCache
+// 写入带过期时间的key并登记到轮询集合, 过期后触发已注册的处理函数
+func SetExpireCache(key string, value interface{}, timeout int, mustNotExists bool) error {
+	if cc == nil {
+		return errors.New("cc is nil")
+	}
+	if err := cc.Set(key, value, timeout, 0, false, mustNotExists); err != nil {
+		return err
+	}
+	deadline := time.Now().Unix() + int64(timeout)
+	return ZAddCache(expireWatchKey, map[string]float64{key: float64(deadline)})
+}
+
+// RenewExpireCache
+// 延长SetExpireCache设置的key, key已不存在时返回false
+func RenewExpireCache(key string, timeout int) bool {
+	if !ExpireCache(key, int64(timeout)) {
+		return false
+	}
+	deadline := time.Now().Unix() + int64(timeout)
+	ZAddCache(expireWatchKey, map[string]float64{key: float64(deadline)})
+	return true
+}
+
+// UnwatchExpire
+// 主动删除key时调用, 避免轮询再次触发
+func UnwatchExpire(key string) {
+	ZRemCache(expireWatchKey, key)
+}
+
+// InitListener
+// 优先订阅redis过期事件, 服务端未开启notify-keyspace-events且无权限开启时降级为轮询
+func InitListener() {
+	if cc == nil {
+		log.Warn("[listener] cache is not ready, listener disabled")
+		return
+	}
+	interval, err := beego.AppConfig.Int("listener::poll_interval")
+	if err != nil || interval <= 0 {
+		interval = 5
+	}
+	listenerStop = make(chan struct{})
+
+	enabled, err := cc.EnableExpiredEvents()
+	if !enabled {
+		log.Warnf("[listener] keyspace notifications unavailable, fallback to polling every %ds, err:%v", interval, err)
+		go pollExpired(time.Duration(interval) * time.Second)
+		return
+	}
+	go subscribeExpired(time.Duration(interval) * time.Second)
+}
+
+// StopListener
+func StopListener() {
+	if listenerStop != nil {
+		close(listenerStop)
+		listenerStop = nil
+	}
+}
+
+// 订阅过期事件, 断线后先补扫一次轮询集合再重连
+func subscribeExpired(retry time.Duration) {
+	stop := listenerStop
+	for {
+		sweepExpired()
+		err := cc.SubscribeExpired(stop, dispatchExpired)
+		if err == nil {
+			return
+		}
+		log.Errorf("[listener] subscribe expired events failed, retry in %s, err:%s", retry, err.Error())
+		select {
+		case <-stop:
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+func pollExpired(interval time.Duration) {
+	stop := listenerStop
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			sweepExpired()
+		}
+	}
+}
+
+// 扫描已到期且已不存在的key
+func sweepExpired() {
+	keys, err := ZRangeByScoreCache(expireWatchKey, 0, time.Now().Unix(), false)
+	if err != nil {
+		log.Errorf("[listener] sweep expired failed, err:%s", err.Error())
+		return
+	}
+	for _, key := range keys {
+		if exists, err := ExistsCache(key); err != nil || exists {
+			continue
+		}
+		dispatchExpired(key)
+	}
+}
+
+// 分发过期事件, 以ZREM结果作为认领, 多实例下同一key只处理一次
+func dispatchExpired(key string) {
+	if n, err := ZRemCache(expireWatchKey, key); err != nil || n == 0 {
+		return
+	}
+
+	expireMu.RLock()
+	var handler ExpireHandler
+	matched := ""
+	for prefix, h := range expireHandlers {
+		if strings.HasPrefix(key, prefix) && len(prefix) > len(matched) {
+			matched, handler = prefix, h
+		}
+	}
+	expireMu.RUnlock()
+	if handler == nil {
+		return
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("[listener] expire handler panic, key:%s, err:%v", key, r)
+		}
+	}()
+	handler(key)
+}
+
+// SplitExpireKey
+// 拆分 prefix:merchantId:name 形式的key
+func SplitExpireKey(key, prefix string) (merchantId int, name string) {
+	parts := strings.SplitN(strings.TrimPrefix(key, prefix), ":", 2)
+	if len(parts) != 2 {
+		return 0, ""
+	}
+	merchantId, _ = strconv.Atoi(parts[0])
+	return merchantId, parts[1]
+}
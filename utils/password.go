@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"crypto/subtle"
+	"strings"
+	"sync"
+
+	"github.com/astaxie/beego"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 密码哈希, bcrypt自带随机盐; 前端提交的是md5(pwd), 服务端对其再做bcrypt
+
+func passwordCost() int {
+	cost, err := beego.AppConfig.Int("password::bcrypt_cost")
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// HashPassword 生成密码哈希
+func HashPassword(pwd string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(pwd), passwordCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+// CheckPassword 校验密码
+// rehash为true表示校验通过但哈希需要升级(旧的md5哈希或cost已调整), 调用方应重新HashPassword后保存
+func CheckPassword(hashed, pwd string) (ok, rehash bool) {
+	if !strings.HasPrefix(hashed, "$2") {
+		legacy := String2md5(pwd)
+		return subtle.ConstantTimeCompare([]byte(hashed), []byte(legacy)) == 1, true
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashed), []byte(pwd)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hashed))
+	return true, err != nil || cost != passwordCost()
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// CheckDummyPassword 账号不存在时也做一次bcrypt比较, 使响应时间与账号存在时一致, 防止据此探测用户名
+func CheckDummyPassword(pwd string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("bossbar-dummy-password"), passwordCost())
+	})
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(pwd))
+}
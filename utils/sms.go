@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"BossBar/sms"
+	_ "BossBar/sms/logsender"
+	"errors"
+	"fmt"
+
+	"github.com/astaxie/beego"
+	log "github.com/sirupsen/logrus"
+)
+
+// 验证码有效期(秒)、重发间隔(秒)、最多尝试次数
+const (
+	verifyCodeTimeout  = 300
+	verifyCodeInterval = 60
+	verifyCodeAttempts = 5
+)
+
+var smsSender sms.Sender
+
+func InitSms() {
+	adapter := beego.AppConfig.DefaultString("sms::adapter", "log")
+	config := beego.AppConfig.DefaultString("sms::config", "{}")
+	var err error
+	smsSender, err = sms.NewSender(adapter, config)
+	if err != nil {
+		log.Errorf("Init sms adapter %s failed, err:%s", adapter, err.Error())
+	}
+}
+
+func verifyCodeKey(scene, phone string) string {
+	return fmt.Sprintf("verify_code:%s:%s", scene, phone)
+}
+
+// SendVerifyCode 发送短信验证码, scene区分业务场景如注册
+func SendVerifyCode(scene, phone string) error {
+	if smsSender == nil {
+		return errors.New("短信服务不可用")
+	}
+	if err := SetNxExCache(fmt.Sprintf("verify_lock:%s:%s", scene, phone), "1", verifyCodeInterval); err != nil {
+		return errors.New("发送太频繁, 请稍后再试")
+	}
+	code := SecureRandomNumString(6)
+	key := verifyCodeKey(scene, phone)
+	if err := SetCache(key, code, verifyCodeTimeout); err != nil {
+		return err
+	}
+	DelCache(key + ":try")
+	content := fmt.Sprintf("您的验证码为%s, %d分钟内有效", code, verifyCodeTimeout/60)
+	if err := smsSender.Send(phone, content); err != nil {
+		log.Errorf("SendVerifyCode failed, phone:%s, err:%s", phone, err.Error())
+		return errors.New("短信发送失败")
+	}
+	return nil
+}
+
+// CheckVerifyCode 校验短信验证码, 成功后验证码作废, 错误次数过多也作废
+func CheckVerifyCode(scene, phone, code string) bool {
+	key := verifyCodeKey(scene, phone)
+	var expect string
+	if code == "" || GetCache(key, &expect) != nil {
+		return false
+	}
+	if expect != code {
+		if tries, _ := IncrByCache(key+":try", 1); tries >= verifyCodeAttempts {
+			DelCache(key)
+		}
+		ExpireCache(key+":try", verifyCodeTimeout)
+		return false
+	}
+	DelCache(key)
+	DelCache(key + ":try")
+	return true
+}
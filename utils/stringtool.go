@@ -3,11 +3,13 @@ package utils
 import (
 	"crypto/hmac"
 	"crypto/md5"
+	crand "crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"hash"
+	"math/big"
 	"math/rand"
 	"net/url"
 	"sort"
@@ -20,6 +22,7 @@ const salt = "aaXQqWMhWlUnUrviX0eqGKPGG4qMF72b"
 const Meaningless = "QqWMhW"
 
 //将字符串加密成 md5
+//仅用于校验旧密码哈希, 新密码请使用 HashPassword
 func String2md5(str string) string {
 	data := []byte(str)
 	has := md5.Sum(data)
@@ -56,9 +59,9 @@ func RandomString(length int) string {
 		if t < 10 {
 			result = append(result, strconv.Itoa(rand.Intn(10)))
 		} else if t < 36 {
-			result = append(result, string(rand.Intn(26)+65))
+			result = append(result, string(rune(rand.Intn(26)+65)))
 		} else {
-			result = append(result, string(rand.Intn(26)+97))
+			result = append(result, string(rune(rand.Intn(26)+97)))
 		}
 	}
 	return strings.Join(result, "")
@@ -78,6 +81,31 @@ func RandomNumString(length int) string {
 	return strings.Join(result, "")
 }
 
+const randomChars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// SecureRandomString 使用crypto/rand生成随机字符串, 用于token id、邀请码等不可猜测的场景
+func SecureRandomString(length int) string {
+	return secureRandom(randomChars, length)
+}
+
+// SecureRandomNumString 使用crypto/rand生成随机数字串, 用于验证码、配对码
+func SecureRandomNumString(length int) string {
+	return secureRandom(randomChars[:10], length)
+}
+
+func secureRandom(chars string, length int) string {
+	max := big.NewInt(int64(len(chars)))
+	result := make([]byte, length)
+	for i := range result {
+		n, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		result[i] = chars[n.Int64()]
+	}
+	return string(result)
+}
+
 func GetStringByParams(params url.Values, delKey ...string) []string {
 
 	//获取排序前，删除不需要的key
@@ -154,6 +182,14 @@ func ParsingSensitiveInformation(data string) string {
 	return string(rawData1)
 }
 
+// MaskPhone 手机号打码, 保留前3位和后4位
+func MaskPhone(phone string) string {
+	if len(phone) < 8 {
+		return strings.Repeat("*", len(phone))
+	}
+	return phone[:3] + strings.Repeat("*", len(phone)-7) + phone[len(phone)-4:]
+}
+
 func CompleteImgUrl(imgStr string) string {
 	if imgStr == "" {
 		return ""
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"BossBar/conf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// xsrf签名密钥最短长度
+const xsrfKeyMinLen = 16
+
+// InitXsrf 检查xsrf签名密钥, 未配置时拒绝启动
+func InitXsrf() {
+	if len(conf.XsrfKey) < xsrfKeyMinLen {
+		log.Fatalf("[xsrf] xsrf::key is required and must be at least %d characters", xsrfKeyMinLen)
+	}
+}
+
+// xsrf token格式: 随机串.签名, 签名防止子域写入伪造的cookie
+func xsrfSign(nonce string) string {
+	mac := hmac.New(sha256.New, []byte(conf.XsrfKey))
+	mac.Write([]byte("xsrf:" + nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateXsrfToken 生成xsrf token
+func GenerateXsrfToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	nonce := hex.EncodeToString(buf)
+	return nonce + "." + xsrfSign(nonce)
+}
+
+// ValidXsrfToken 校验xsrf token签名
+func ValidXsrfToken(token string) bool {
+	i := strings.IndexByte(token, '.')
+	if i <= 0 {
+		return false
+	}
+	return hmac.Equal([]byte(token[i+1:]), []byte(xsrfSign(token[:i])))
+}